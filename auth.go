@@ -1,55 +1,145 @@
 package goseafile
 
 // This file implements authentication token caching for user/password
-// combinations. It saves the authentication tokens, encrypted with the 
-// SHA256 of the provided password, so you need to specify the user's
-// password in order to unlock the authentication token. This prevents
-// unnecessary posts of the user's password over public connections.
+// combinations. The FileTokenStore saves the authentication tokens encrypted
+// with AES-GCM, using a key derived from the provided password with PBKDF2,
+// so you need to specify the user's password in order to unlock the
+// authentication token. This prevents unnecessary posts of the user's
+// password over public connections.
 // Files are stored in ~/.config/goseafile/tokens.json (the user's home
-// directory)
+// directory) by default.
+//
+// Token file versions:
+// - v0: a map of MD5(url##user) to the token encrypted with AES-CFB, using
+//   the unsalted SHA256 of the password as key. Still read, and migrated to
+//   v1 the next time the file is written.
+// - v1: a map of SHA256(url##user) to the token encrypted with AES-GCM,
+//   using a PBKDF2 derived key with a random salt per token.
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/md5"
+	"crypto/pbkdf2"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/user"
 	"path"
+	"sort"
 	"time"
 )
 
+// tokenFileVersion is the current version of the token file format
+const tokenFileVersion = 1
+
+// pbkdf2Iter is the number of PBKDF2 iterations used for new tokens
+const pbkdf2Iter = 600000
+
+// tokenFile is the content of the token file
+type tokenFile struct {
+	Version int
+	Tokens  map[string]storedAuth
+	// Legacy holds v0 tokens, which can only be migrated once their
+	// password is known
+	Legacy map[string]legacyAuth `json:",omitempty"`
+}
+
+// storedAuth is an encrypted token as stored in the token file
 type storedAuth struct {
+	Salt      []byte
+	Iter      int
+	Nonce     []byte
 	Token     []byte
 	TimeStamp time.Time
-	DecToken  string `json:"-"`
+	// LastValidated is not part of the encrypted data, so it can be updated
+	// without deriving the key again
+	LastValidated time.Time `json:",omitempty"`
+	// Url and User are kept in clear, so the tokens can be listed without
+	// the passwords
+	Url  string `json:",omitempty"`
+	User string `json:",omitempty"`
 }
 
-func encrypt(key, text []byte) ([]byte, error) {
+// legacyAuth is a token as stored in a v0 token file
+type legacyAuth struct {
+	Token     []byte
+	TimeStamp time.Time
+}
+
+func newGCM(password string, salt []byte, iter int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iter, 32)
+	if err != nil {
+		return nil, err
+	}
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	b := base64.StdEncoding.EncodeToString(text)
-	ciphertext := make([]byte, aes.BlockSize+len(b))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+	return cipher.NewGCM(block)
+}
+
+// tokenAD returns the additional data authenticated along with the token:
+// the token id and timestamp, so an encrypted token can't be moved to another
+// entry and its timestamp can't be changed.
+func tokenAD(id string, ts time.Time) []byte {
+	return []byte(id + "##" + ts.UTC().Format(time.RFC3339Nano))
+}
+
+// encrypt encrypts the token with the password
+func encrypt(password, id, token string, ts time.Time) (*storedAuth, error) {
+	sa := &storedAuth{
+		Salt:      make([]byte, 16),
+		Iter:      pbkdf2Iter,
+		TimeStamp: ts,
+	}
+	if _, err := io.ReadFull(rand.Reader, sa.Salt); err != nil {
 		return nil, err
 	}
-	cfb := cipher.NewCFBEncrypter(block, iv)
-	cfb.XORKeyStream(ciphertext[aes.BlockSize:], []byte(b))
-	return ciphertext, nil
+	gcm, err := newGCM(password, sa.Salt, sa.Iter)
+	if err != nil {
+		return nil, err
+	}
+	sa.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, sa.Nonce); err != nil {
+		return nil, err
+	}
+	sa.Token = gcm.Seal(nil, sa.Nonce, []byte(token), tokenAD(id, ts))
+	return sa, nil
 }
 
-func decrypt(key, text []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
+// decrypt decrypts the token with the password, failing if the password is
+// wrong or the entry was tampered with.
+func decrypt(password, id string, sa storedAuth) (string, error) {
+	if sa.Iter <= 0 {
+		return "", fmt.Errorf("invalid iteration count: %d", sa.Iter)
+	}
+	gcm, err := newGCM(password, sa.Salt, sa.Iter)
+	if err != nil {
+		return "", err
+	}
+	if len(sa.Nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid nonce")
+	}
+	tok, err := gcm.Open(nil, sa.Nonce, sa.Token, tokenAD(id, sa.TimeStamp))
+	if err != nil {
+		return "", err
+	}
+	return string(tok), nil
+}
+
+// decryptV0 decrypts a token from a v0 token file
+func decryptV0(password string, text []byte) ([]byte, error) {
+	// The AES-256 key is the SHA256 of the password
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +147,7 @@ func decrypt(key, text []byte) ([]byte, error) {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
 	iv := text[:aes.BlockSize]
-	text = text[aes.BlockSize:]
+	text = append([]byte(nil), text[aes.BlockSize:]...)
 	cfb := cipher.NewCFBDecrypter(block, iv)
 	cfb.XORKeyStream(text, text)
 	data, err := base64.StdEncoding.DecodeString(string(text))
@@ -67,167 +157,405 @@ func decrypt(key, text []byte) ([]byte, error) {
 	return data, nil
 }
 
-func (sf *SeaFile) getAESKey() []byte {
-	// Create a AES key of 32 bytes to select AES-256
-	//return []byte(strings.Repeat(conf.Password, (32 / len(conf.Password)) + 1))[0:32]
-	ret := sha256.Sum256([]byte(sf.Password))
-	return ret[0:32]
+func tokenId(key TokenKey) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key.Url+"##"+key.User)))
+}
+
+func legacyTokenId(key TokenKey) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(key.Url+"##"+key.User)))
 }
 
-func (sf *SeaFile) getTokId() string {
-	return fmt.Sprintf("%x", md5.Sum([]byte(sf.Url+"##"+sf.User)))
+// errCorruptTokenFile indicates the token file could not be parsed
+var errCorruptTokenFile = errors.New("corrupt token file")
+
+// readTokenFile reads and parses a token file of any version. A missing
+// file results in an empty tokenFile.
+func readTokenFile(file string) (*tokenFile, error) {
+	tf := &tokenFile{
+		Version: tokenFileVersion,
+		Tokens:  make(map[string]storedAuth),
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return tf, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Version int
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("%w: could not unmarshal '%s' contents: %s", errCorruptTokenFile, file, err)
+	}
+	switch probe.Version {
+	case 0:
+		// v0 files are a plain map of token id to token
+		legacy := make(map[string]legacyAuth)
+		if err := json.Unmarshal(b, &legacy); err != nil {
+			return nil, fmt.Errorf("%w: could not unmarshal '%s' contents: %s", errCorruptTokenFile, file, err)
+		}
+		tf.Legacy = legacy
+	case tokenFileVersion:
+		if err := json.Unmarshal(b, tf); err != nil {
+			return nil, fmt.Errorf("%w: could not unmarshal '%s' contents: %s", errCorruptTokenFile, file, err)
+		}
+		if tf.Tokens == nil {
+			tf.Tokens = make(map[string]storedAuth)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token file version %d in '%s'", probe.Version, file)
+	}
+	return tf, nil
 }
 
-func (sf *SeaFile) getFilePath() string {
+// DefaultTokenFile returns the default location of the token file,
+// ~/.config/goseafile/tokens.json
+func DefaultTokenFile() (string, error) {
 	if u, err := user.Current(); err == nil {
-		return path.Clean(u.HomeDir + "/.config/goseafile/tokens.json")
+		return path.Clean(u.HomeDir + "/.config/goseafile/tokens.json"), nil
 	} else {
-		log.Printf("[ERROR] could not get user: %s\n", err)
+		return "", err
 	}
-	return ""
 }
 
-func (sf *SeaFile) getFileToken(file string) *storedAuth {
-	if file == "" {
-		return nil
+// FileTokenStore is a TokenStore saving the tokens in a JSON file, encrypted
+// with the user's password. Tokens can only be stored and loaded when the
+// password is known.
+type FileTokenStore struct {
+	// Path is the location of the token file. If empty, DefaultTokenFile()
+	// is used.
+	Path string
+	// MaxAge is the age after which tokens of any user are removed from the
+	// file when it is rewritten. If 0, tokens are kept.
+	MaxAge time.Duration
+	// Logger receives the log output. If nil, nothing is logged.
+	Logger Logger
+}
+
+func (fs *FileTokenStore) logger() Logger {
+	if fs.Logger != nil {
+		return fs.Logger
 	}
-	key := sf.getAESKey()
-	if key == nil {
-		log.Printf("[WARN] Could not get AES key\n")
-		return nil
+	return nopLogger{}
+}
+
+func (fs *FileTokenStore) file() (string, error) {
+	if fs.Path != "" {
+		return fs.Path, nil
 	}
-	if b, err := ioutil.ReadFile(file); err == nil {
-		keys := make(map[string]storedAuth)
-		if err := json.Unmarshal(b, &keys); err == nil {
-			if k, ok := keys[sf.getTokId()]; ok {
-				if btok, err := decrypt(key, k.Token); err != nil {
-					log.Printf("[ERROR] could not decrypt token: %s\n", err)
-				} else {
-					k.DecToken = string(btok)
-					return &k
-				}
-			} else {
-				log.Printf("[WARN] Token not found for %s (%s@%s)\n", sf.getTokId(), sf.User, sf.Url)
-			}
-		} else {
-			log.Printf("[WARN] Could not unmarshal '%s' contents: %s\n", file, err)
+	return DefaultTokenFile()
+}
+
+// Load returns the token stored for the key, or nil if there is none.
+func (fs *FileTokenStore) Load(key TokenKey) (*StoredToken, error) {
+	if key.Password == "" {
+		return nil, nil
+	}
+	file, err := fs.file()
+	if err != nil {
+		return nil, err
+	}
+	tf, err := readTokenFile(file)
+	if errors.Is(err, errCorruptTokenFile) {
+		// It will be recovered the next time it is written
+		fs.logger().Warn("Ignoring corrupt token file", "error", err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	id := tokenId(key)
+	if sa, ok := tf.Tokens[id]; ok {
+		tok, err := decrypt(key.Password, id, sa)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt token: %s", err)
 		}
-	} else {
-		log.Printf("[WARN] Could not read file '%s': %s\n", file, err)
+		return &StoredToken{
+			Token:         tok,
+			TimeStamp:     sa.TimeStamp,
+			LastValidated: sa.LastValidated,
+		}, nil
 	}
-	return nil
+	if la, ok := tf.Legacy[legacyTokenId(key)]; ok {
+		btok, err := decryptV0(key.Password, la.Token)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt token: %s", err)
+		}
+		return &StoredToken{
+			Token:     string(btok),
+			TimeStamp: la.TimeStamp,
+		}, nil
+	}
+	fs.logger().Debug("Token not found", "id", id, "user", key.User, "url", key.Url)
+	return nil, nil
 }
 
-func (sf *SeaFile) setFileToken(file, token string, expire time.Duration) error {
-	if file == "" {
-		return fmt.Errorf("file given was empty")
+// Save stores the token for the key
+func (fs *FileTokenStore) Save(key TokenKey, tok StoredToken) error {
+	if key.Password == "" {
+		return fmt.Errorf("a password is required to encrypt the token")
 	}
-	key := sf.getAESKey()
-	if key == nil {
-		return nil
-	}
-	if btok, err := encrypt(key, []byte(token)); err == nil {
-		// Create directory if it doesn't exist
-		if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+	id := tokenId(key)
+	return fs.update(func(tf *tokenFile) error {
+		if sa, ok := tf.Tokens[id]; ok && sa.TimeStamp.Equal(tok.TimeStamp) {
+			// Same token, only the validation time changed
+			sa.LastValidated = tok.LastValidated
+			sa.Url, sa.User = key.Url, key.User
+			tf.Tokens[id] = sa
+			return nil
+		}
+		sa, err := encrypt(key.Password, id, tok.Token, tok.TimeStamp)
+		if err != nil {
 			return err
 		}
-		keys := make(map[string]storedAuth)
-		// read the existing tokpath
-		if b, err := ioutil.ReadFile(file); err == nil {
-			if err := json.Unmarshal(b, &keys); err != nil {
-				return err
-			}
-		} else {
-			log.Printf("[WARN] Could not re-read file '%s' -- ignoring\n", file)
-		}
-		if expire > 0 {
-			// remove expired tokens
-			now := time.Now()
-			for kt := range keys {
-				if now.Sub(keys[kt].TimeStamp) > expire {
-					log.Printf("[DEBUG] Removing expired key: %q\n", keys[kt])
-					delete(keys, kt)
-				}
+		sa.LastValidated = tok.LastValidated
+		sa.Url, sa.User = key.Url, key.User
+		tf.Tokens[id] = *sa
+		delete(tf.Legacy, legacyTokenId(key))
+		return nil
+	})
+}
+
+// Delete removes the token stored for the key
+func (fs *FileTokenStore) Delete(key TokenKey) error {
+	return fs.update(func(tf *tokenFile) error {
+		delete(tf.Tokens, tokenId(key))
+		delete(tf.Legacy, legacyTokenId(key))
+		return nil
+	})
+}
+
+// TokenInfo describes a token in the token file, without the token itself
+type TokenInfo struct {
+	// Url and User are empty for tokens saved by older versions
+	Url           string
+	User          string
+	TimeStamp     time.Time
+	LastValidated time.Time
+}
+
+// List returns the tokens in the token file, without decrypting them
+func (fs *FileTokenStore) List() ([]TokenInfo, error) {
+	file, err := fs.file()
+	if err != nil {
+		return nil, err
+	}
+	tf, err := readTokenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var ret []TokenInfo
+	for _, sa := range tf.Tokens {
+		ret = append(ret, TokenInfo{
+			Url:           sa.Url,
+			User:          sa.User,
+			TimeStamp:     sa.TimeStamp,
+			LastValidated: sa.LastValidated,
+		})
+	}
+	for _, la := range tf.Legacy {
+		ret = append(ret, TokenInfo{
+			TimeStamp: la.TimeStamp,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].TimeStamp.Before(ret[j].TimeStamp) })
+	return ret, nil
+}
+
+// Purge removes the tokens older than maxAge from the token file, or all
+// tokens if maxAge is 0. It returns the number of removed tokens.
+func (fs *FileTokenStore) Purge(maxAge time.Duration) (int, error) {
+	n := 0
+	now := time.Now()
+	// Count the expired tokens here instead of silently removing them
+	nfs := *fs
+	nfs.MaxAge = 0
+	err := nfs.update(func(tf *tokenFile) error {
+		for id, sa := range tf.Tokens {
+			if maxAge == 0 || now.Sub(sa.TimeStamp) > maxAge {
+				delete(tf.Tokens, id)
+				n++
 			}
 		}
-		id := sf.getTokId()
-		if token == "" {
-			if _, ok := keys[id]; ok {
-				delete(keys, id)
-			}
-		} else {
-			// append new token
-			keys[id] = storedAuth{
-				Token:     btok,
-				TimeStamp: time.Now(),
+		for id, la := range tf.Legacy {
+			if maxAge == 0 || now.Sub(la.TimeStamp) > maxAge {
+				delete(tf.Legacy, id)
+				n++
 			}
 		}
-		// marshal and rewrite config file
-		if bytes, err := json.Marshal(keys); err != nil {
-			return err
-		} else if err := ioutil.WriteFile(file, bytes, 0600); err != nil {
+		return nil
+	})
+	return n, err
+}
+
+// update rewrites the token file after applying fn to its contents. The file
+// is always written in the current format. Concurrent updates from several
+// processes are serialized with a lock file next to the token file.
+func (fs *FileTokenStore) update(fn func(*tokenFile) error) error {
+	file, err := fs.file()
+	if err != nil {
+		return err
+	}
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tf, err := readTokenFile(file)
+	if errors.Is(err, errCorruptTokenFile) {
+		// Keep the corrupt file for inspection and start over
+		fs.logger().Warn("Recovering from corrupt token file", "error", err)
+		if err := os.Rename(file, file+".corrupt"); err != nil {
 			return err
 		}
-	} else {
+		tf, err = readTokenFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	if fs.MaxAge > 0 {
+		// remove expired tokens
+		now := time.Now()
+		for kt := range tf.Tokens {
+			if now.Sub(tf.Tokens[kt].TimeStamp) > fs.MaxAge {
+				fs.logger().Debug("Removing expired token", "id", kt, "timestamp", tf.Tokens[kt].TimeStamp)
+				delete(tf.Tokens, kt)
+			}
+		}
+		for kt := range tf.Legacy {
+			if now.Sub(tf.Legacy[kt].TimeStamp) > fs.MaxAge {
+				fs.logger().Debug("Removing expired token", "id", kt, "timestamp", tf.Legacy[kt].TimeStamp)
+				delete(tf.Legacy, kt)
+			}
+		}
+	}
+	if err := fn(tf); err != nil {
+		return err
+	}
+	tf.Version = tokenFileVersion
+	// marshal and rewrite config file
+	if bytes, err := json.Marshal(tf); err != nil {
+		return err
+	} else if err := writeFileAtomic(file, bytes, 0600); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it over
+// the target, so readers never see a partially written file.
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(path.Dir(file), "."+path.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
 		return err
 	}
 	return nil
 }
 
-func (sf *SeaFile) doAuth(token string) bool {
+func (sf *SeaFile) doAuth(ctx context.Context, token string) bool {
 	if token == "" {
 		return false
 	}
 	sf.AuthToken = token
-	if sf.Authed() {
+	if sf.AuthedContext(ctx) {
 		return true
 	}
-	log.Printf("[WARN] Token '%s' invalid\n", token)
+	sf.logger().Warn("Token invalid", "token", redact(token))
 	return false
 }
 
-func (sf *SeaFile) tryAuth() bool {
+// tryAuth authenticates with a cached token or by logging in with the
+// password. It returns nil when authenticated.
+func (sf *SeaFile) tryAuth(ctx context.Context) error {
 	// order to try authentication tokens:
 	// - stored if valid/available AND user/pass combination is available
-	// Cache auth tokens in ${HOME}/.config/goseafile/tokens.json
+	// Cache auth tokens in the TokenStore, by default
+	// ${HOME}/.config/goseafile/tokens.json
 	// - encrypt with hash of password
 
-	var tokpath string
-	var maxtime = 30 * time.Minute
-	log.Println("[DEBUG] Trying to authenticate...")
+	ttl := sf.tokenTTL()
+	sf.logger().Debug("Trying to authenticate...")
 	tok := ""
-	tokpath = sf.getFilePath()
-	if st := sf.getFileToken(tokpath); st != nil {
-		log.Printf("[DEBUG] Existing token found: %q\n", st)
-		if time.Now().Sub(st.TimeStamp) < maxtime {
-			log.Printf("[DEBUG] Token %s still valid!\n", st.DecToken)
-			tok = st.DecToken
+	var stamp time.Time
+	store := sf.tokenStore()
+	key := sf.tokenKey()
+	if st, err := store.Load(key); err != nil {
+		sf.logger().Warn("Could not load stored token", "error", err)
+	} else if st != nil {
+		sf.logger().Debug("Existing token found", "timestamp", st.TimeStamp, "last_validated", st.LastValidated)
+		if ttl < 0 || time.Now().Sub(st.TimeStamp) < ttl {
+			sf.logger().Debug("Token still valid", "token", redact(st.Token))
+			tok = st.Token
+			stamp = st.TimeStamp
 		} else {
-			log.Printf("[WARN] Token found but not valid anymore\n")
+			sf.logger().Warn("Token found but not valid anymore")
 		}
 	}
 
-	if sf.doAuth(tok) {
-		return true
+	if sf.doAuth(ctx, tok) {
+		// Record that the server still accepts the token
+		st := StoredToken{
+			Token:         tok,
+			TimeStamp:     stamp,
+			LastValidated: time.Now(),
+		}
+		if err := store.Save(key, st); err != nil {
+			sf.logger().Warn("Could not save auth token", "error", err)
+		}
+		return nil
 	} else if tok != "" {
-		log.Printf("[WARN] Auth failed with stored token -- removing token '%s'", tok)
-		if err := sf.setFileToken(tokpath, "", maxtime); err != nil {
-			log.Printf("[WARN] Could not remove invalid auth token: %s\n", err)
+		sf.logger().Warn("Auth failed with stored token -- removing token", "token", redact(tok))
+		if err := store.Delete(key); err != nil {
+			sf.logger().Warn("Could not remove invalid auth token", "error", err)
 		}
 	}
 
 	if sf.Password == "" {
-		return false
+		return AuthError
 	}
-	if err := sf.Login(sf.User, sf.Password); err != nil {
-		log.Printf("[ERROR] no valid authentication found (auth error: %s)\n", err)
-		return false
+	// The server denies a login sending a rejected token
+	sf.AuthToken = ""
+	if err := sf.LoginContext(ctx, sf.User, sf.Password); err != nil {
+		sf.logger().Error("No valid authentication found", "error", err)
+		return err
 	}
-	log.Printf("[DEBUG] Auth succeeded!\n")
+	sf.logger().Debug("Auth succeeded")
 	// Now store the auth token
-	if err := sf.setFileToken(tokpath, sf.AuthToken, maxtime); err != nil {
-		log.Printf("[WARN] Could not save auth token: %s\n", err)
+	now := time.Now()
+	st := StoredToken{
+		Token:         sf.AuthToken,
+		TimeStamp:     now,
+		LastValidated: now,
+	}
+	if err := store.Save(key, st); err != nil {
+		sf.logger().Warn("Could not save auth token", "error", err)
 	}
-	return true
+	return nil
 }
-
@@ -0,0 +1,216 @@
+package goseafile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// encryptV0 encrypts a token like v0 token files did
+func encryptV0(t *testing.T, password, token string) []byte {
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := base64.StdEncoding.EncodeToString([]byte(token))
+	text := make([]byte, aes.BlockSize+len(b))
+	iv := text[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCFBEncrypter(block, iv).XORKeyStream(text[aes.BlockSize:], []byte(b))
+	return text
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	sa, err := encrypt("secret", "id", "token", ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		password string
+		id       string
+		modify   func(sa *storedAuth)
+		wantErr  bool
+	}{
+		{"valid", "secret", "id", nil, false},
+		{"wrong password", "wrong", "id", nil, true},
+		{"moved to other id", "secret", "other", nil, true},
+		{"changed timestamp", "secret", "id", func(sa *storedAuth) { sa.TimeStamp = sa.TimeStamp.Add(time.Hour) }, true},
+		{"tampered token", "secret", "id", func(sa *storedAuth) { sa.Token[0] ^= 1 }, true},
+		{"invalid nonce", "secret", "id", func(sa *storedAuth) { sa.Nonce = sa.Nonce[1:] }, true},
+		{"invalid iterations", "secret", "id", func(sa *storedAuth) { sa.Iter = 0 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *sa
+			c.Token = append([]byte(nil), sa.Token...)
+			if tt.modify != nil {
+				tt.modify(&c)
+			}
+			tok, err := decrypt(tt.password, tt.id, c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decrypt succeeded, want error")
+				}
+			} else if err != nil {
+				t.Errorf("decrypt: %s", err)
+			} else if tok != "token" {
+				t.Errorf("decrypt = %q, want %q", tok, "token")
+			}
+		})
+	}
+}
+
+func TestDecryptV0(t *testing.T) {
+	text := encryptV0(t, "secret", "token")
+	tests := []struct {
+		name     string
+		password string
+		text     []byte
+		want     string
+		wantErr  bool
+	}{
+		{"valid", "secret", text, "token", false},
+		{"too short", "secret", text[:aes.BlockSize-1], "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := decryptV0(tt.password, tt.text)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decryptV0 succeeded, want error")
+				}
+			} else if err != nil {
+				t.Errorf("decryptV0: %s", err)
+			} else if string(b) != tt.want {
+				t.Errorf("decryptV0 = %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenFileMigration(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens.json")
+	key := TokenKey{Url: "https://seafile.example.com/api2", User: "user", Password: "secret"}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v0 := map[string]legacyAuth{
+		legacyTokenId(key): {Token: encryptV0(t, key.Password, "token"), TimeStamp: ts},
+	}
+	if b, err := json.Marshal(v0); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := &FileTokenStore{Path: file}
+	st, err := fs.Load(key)
+	if err != nil {
+		t.Fatalf("Load v0: %s", err)
+	} else if st == nil || st.Token != "token" || !st.TimeStamp.Equal(ts) {
+		t.Fatalf("Load v0 = %+v, want token with timestamp %s", st, ts)
+	}
+
+	// Saving the token migrates it to the current format
+	if err := fs.Save(key, *st); err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+	tf, err := readTokenFile(file)
+	if err != nil {
+		t.Fatalf("readTokenFile: %s", err)
+	}
+	if tf.Version != tokenFileVersion {
+		t.Errorf("Version = %d, want %d", tf.Version, tokenFileVersion)
+	}
+	if len(tf.Legacy) != 0 {
+		t.Errorf("Legacy has %d tokens, want none", len(tf.Legacy))
+	}
+	if _, ok := tf.Tokens[tokenId(key)]; !ok {
+		t.Errorf("migrated token not found")
+	}
+	if st, err := fs.Load(key); err != nil {
+		t.Fatalf("Load v1: %s", err)
+	} else if st == nil || st.Token != "token" || !st.TimeStamp.Equal(ts) {
+		t.Fatalf("Load v1 = %+v, want token with timestamp %s", st, ts)
+	}
+}
+
+func TestReadTokenFileVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"v0", `{"0123":{"Token":"AAAA","TimeStamp":"2024-01-02T03:04:05Z"}}`, false},
+		{"v1", `{"Version":1,"Tokens":{}}`, false},
+		{"v1 without tokens", `{"Version":1}`, false},
+		{"unsupported version", `{"Version":99}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "tokens.json")
+			if err := ioutil.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			tf, err := readTokenFile(file)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readTokenFile succeeded, want error")
+				}
+			} else if err != nil {
+				t.Errorf("readTokenFile: %s", err)
+			} else if tf.Tokens == nil {
+				t.Errorf("Tokens is nil")
+			}
+		})
+	}
+}
+
+func TestCorruptTokenFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"garbage", "not json"},
+		{"truncated", `{"Version":1,"Tokens":{"01`},
+		{"wrong type", `{"Version":1,"Tokens":[]}`},
+	}
+	key := TokenKey{Url: "https://seafile.example.com/api2", User: "user", Password: "secret"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "tokens.json")
+			if err := ioutil.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			fs := &FileTokenStore{Path: file}
+			// A corrupt file is ignored when loading
+			if st, err := fs.Load(key); err != nil || st != nil {
+				t.Fatalf("Load = %+v, %v, want nil, nil", st, err)
+			}
+			// and replaced when writing, keeping the corrupt file
+			if err := fs.Save(key, StoredToken{Token: "token", TimeStamp: time.Now()}); err != nil {
+				t.Fatalf("Save: %s", err)
+			}
+			if b, err := ioutil.ReadFile(file + ".corrupt"); err != nil {
+				t.Errorf("corrupt file not kept: %s", err)
+			} else if string(b) != tt.content {
+				t.Errorf("corrupt file = %q, want %q", b, tt.content)
+			}
+			if st, err := fs.Load(key); err != nil {
+				t.Fatalf("Load: %s", err)
+			} else if st == nil || st.Token != "token" {
+				t.Errorf("Load = %+v, want token", st)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package goseafile
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientConfig holds the transport settings for NewHTTPClient
+type ClientConfig struct {
+	// CAFile is a PEM file with additional CA certificates to trust
+	CAFile string
+	// Insecure disables verification of the server certificate
+	Insecure bool
+	// CertFile and KeyFile are a PEM encoded client certificate and key
+	CertFile string
+	KeyFile  string
+	// Proxy is the URL of the proxy to use. If empty, the proxy is taken
+	// from the environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY).
+	Proxy string
+	// Timeout limits connecting to the server and waiting for the response
+	// headers. It does not limit the total duration of a request, so large
+	// transfers are not aborted.
+	Timeout time.Duration
+}
+
+// NewHTTPClient creates an http.Client with the given settings, to be used as
+// SeaFile.HTTPClient.
+func NewHTTPClient(conf ClientConfig) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tlsconf := &tls.Config{
+		InsecureSkipVerify: conf.Insecure,
+	}
+	if conf.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if b, err := ioutil.ReadFile(conf.CAFile); err != nil {
+			return nil, err
+		} else if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", conf.CAFile)
+		}
+		tlsconf.RootCAs = pool
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile); err != nil {
+			return nil, err
+		} else {
+			tlsconf.Certificates = []tls.Certificate{cert}
+		}
+	}
+	tr.TLSClientConfig = tlsconf
+	if conf.Proxy != "" {
+		if u, err := url.Parse(conf.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy url '%s': %s", conf.Proxy, err)
+		} else {
+			tr.Proxy = http.ProxyURL(u)
+		}
+	}
+	if conf.Timeout > 0 {
+		tr.DialContext = (&net.Dialer{
+			Timeout:   conf.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		tr.TLSHandshakeTimeout = conf.Timeout
+		tr.ResponseHeaderTimeout = conf.Timeout
+	}
+	return &http.Client{Transport: tr}, nil
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"bufio"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 	
@@ -26,9 +31,31 @@ type Config struct {
 	AuthToken string
 	Library   string
 	Script    string
+
+	// Passwords of encrypted libraries, by library name
+	LibPasswords map[string]string
+
+	// HTTP client settings
+	CAFile   string
+	Insecure bool
+	CertFile string
+	KeyFile  string
+	Proxy    string
+	Timeout  string
+
+	// Fileserver url, defaults to the server url followed by /seafhttp
+	FileServer string
+
+	// Number of times a failed request is retried, nil if not set
+	Retries *int
+
+	// Location of the token cache, defaults to ~/.config/goseafile/tokens.json
+	TokenFile string
+	// How long a cached token is used, e.g. 12h, or "until-rejected"
+	TokenTTL string
 }
 
-type CmdRun func(string, *goseafile.SeaFile, *Config, []string) error
+type CmdRun func(context.Context, string, *goseafile.SeaFile, *Config, []string) error
 
 var buildDate string
 var buildHash string
@@ -36,20 +63,57 @@ var verMaj string
 var verMin string
 
 var cmdList = map[string]CmdRun{
-	"list":     listCmd,
-	"listlibs": listLibsCmd,
-	"upload":   uploadCmd,
-	"download": downloadCmd,
-	"setlib":   setVal,
-	"lib":      setVal,
-	"library":  setVal,
-	"user":     setVal,
-	"password": setVal,
-	"pass":     setVal,
-	"url":      setVal,
-}
-
-func setVal(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	"list":            listCmd,
+	"listlibs":        listLibsCmd,
+	"mklib":           mkLibCmd,
+	"rmlib":           rmLibCmd,
+	"renamelib":       renameLibCmd,
+	"mkdir":           mkdirCmd,
+	"rmdir":           rmdirCmd,
+	"mv":              mvCpCmd,
+	"cp":              mvCpCmd,
+	"rm":              rmCmd,
+	"upload":          uploadCmd,
+	"download":        downloadCmd,
+	"libpass":         libPassCmd,
+	"setlib":          setVal,
+	"lib":             setVal,
+	"library":         setVal,
+	"user":            setVal,
+	"password":        setVal,
+	"pass":            setVal,
+	"url":             setVal,
+	"token":           setVal,
+	"otp":             setVal,
+	"login":           loginCmd,
+	"logout":          logoutCmd,
+	"whoami":          whoamiCmd,
+	"tokens":          tokensCmd,
+	"info":            infoCmd,
+	"share":           shareCmd,
+	"listshares":      listSharesCmd,
+	"rmshare":         rmShareCmd,
+	"uploadlink":      uploadLinkCmd,
+	"listuploadlinks": listUploadLinksCmd,
+	"rmuploadlink":    rmUploadLinkCmd,
+	"linkupload":      linkUploadCmd,
+	"fetch":           fetchCmd,
+	"shareuser":       shareUserCmd,
+	"sharegroup":      shareUserCmd,
+	"unshareuser":     unshareCmd,
+	"unsharegroup":    unshareCmd,
+	"listlibshares":   listLibSharesCmd,
+	"listgroups":      listGroupsCmd,
+	"mkgroup":         mkGroupCmd,
+	"rmgroup":         rmGroupCmd,
+	"listmembers":     listMembersCmd,
+	"addmember":       memberCmd,
+	"rmmember":        memberCmd,
+	"listgrouplibs":   listGroupLibsCmd,
+	"mkgrouplib":      mkGroupLibCmd,
+}
+
+func setVal(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
 	if len(args) == 1 {
 		cval := args[0]
 		switch(cmd) {
@@ -68,6 +132,11 @@ func setVal(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) erro
 		case "url":
 			sf.Url = cval
 			sf.AuthToken = ""
+		case "token":
+			sf.AuthToken = cval
+			cval = "********"
+		case "otp":
+			sf.OTP = cval
 		default:
 			return fmt.Errorf("unknown command: %s", cmd)
 		}
@@ -80,8 +149,149 @@ func setVal(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) erro
 	return nil
 }
 
-func listLibsCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
-	if v, err := sf.ListLibraries(); err == nil {
+// getLibrary looks up the library with the given name, registering its
+// password if one was configured.
+func getLibrary(ctx context.Context, sf *goseafile.SeaFile, conf *Config, name string) (*goseafile.Library, error) {
+	l, err := sf.GetLibraryContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if pw, ok := conf.LibPasswords[name]; ok {
+		l.SetPassword(pw)
+	} else if l.Encrypted {
+		log.Printf("[WARN] Library '%s' is encrypted but no password was set\n", name)
+	}
+	return l, nil
+}
+
+func loginCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Useage: login")
+	}
+	// Any authenticated request logs in, using the cached token if possible
+	if ai, err := sf.GetAccountInfoContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("# login '%s' on %s\n", ai.Email, sf.Url)
+	}
+	return nil
+}
+
+func logoutCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Useage: logout")
+	}
+	log.Printf("# logout '%s' on %s\n", sf.User, sf.Url)
+	return sf.LogoutContext(ctx)
+}
+
+func whoamiCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Useage: whoami")
+	}
+	if ai, err := sf.GetAccountInfoContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("%s (%s) on %s\n", ai.Email, ai.Name, sf.Url)
+	}
+	return nil
+}
+
+func tokensCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	useage := fmt.Errorf("Useage: tokens list | tokens purge [--expired]")
+	fs, ok := sf.TokenStore.(*goseafile.FileTokenStore)
+	if !ok {
+		return fmt.Errorf("tokens: no token file configured")
+	}
+	if len(args) == 1 && args[0] == "list" {
+		v, err := fs.List()
+		if err != nil {
+			return err
+		}
+		log.Printf("# tokens list start\n")
+		for _, t := range v {
+			server, user := t.Url, t.User
+			if server == "" {
+				server, user = "<unknown>", "<unknown>"
+			}
+			age := time.Since(t.TimeStamp).Truncate(time.Second)
+			if t.LastValidated.IsZero() {
+				log.Printf("%s %s age %s\n", server, user, age)
+			} else {
+				log.Printf("%s %s age %s validated %s ago\n", server, user, age, time.Since(t.LastValidated).Truncate(time.Second))
+			}
+		}
+		log.Printf("# tokens list end\n")
+	} else if len(args) >= 1 && len(args) <= 2 && args[0] == "purge" {
+		var maxAge time.Duration
+		if len(args) == 2 {
+			if args[1] != "--expired" {
+				return useage
+			}
+			if maxAge = sf.TokenTTL; maxAge == 0 {
+				maxAge = 30 * time.Minute
+			} else if maxAge < 0 {
+				return fmt.Errorf("tokens: tokens don't expire with a token ttl of until-rejected")
+			}
+		}
+		if n, err := fs.Purge(maxAge); err != nil {
+			return err
+		} else {
+			log.Printf("# tokens purge removed %d token(s)\n", n)
+		}
+	} else {
+		return useage
+	}
+	return nil
+}
+
+func infoCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Useage: info")
+	}
+	log.Printf("# info start\n")
+	log.Printf("url: %s\n", sf.Url)
+	if d, err := sf.PingLatencyContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("latency: %s\n", d)
+	}
+	if si, err := sf.ServerInfoContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("version: %s\n", si.Version)
+		log.Printf("edition: %s\n", si.Edition())
+		log.Printf("features: %s\n", strings.Join(si.Features, ", "))
+		log.Printf("encrypted library version: %d\n", si.EncryptedLibraryVersion)
+	}
+	if ai, err := sf.GetAccountInfoContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("account: %s (%s)\n", ai.Email, ai.Name)
+		if ai.Total > 0 {
+			log.Printf("usage: %d of %d bytes\n", ai.Usage, ai.Total)
+		} else {
+			log.Printf("usage: %d bytes\n", ai.Usage)
+		}
+	}
+	log.Printf("# info end\n")
+	return nil
+}
+
+func libPassCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: libpass <password>")
+	}
+	if conf.LibPasswords == nil {
+		conf.LibPasswords = make(map[string]string)
+	}
+	conf.LibPasswords[conf.Library] = args[0]
+	log.Printf("# %s '********'\n", cmd)
+	return nil
+}
+
+func listLibsCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if v, err := sf.ListLibrariesContext(ctx); err == nil {
 		log.Printf("# listlibs start\n")
 		for _, e := range v {
 			log.Printf("%s\n", e.Name)
@@ -93,17 +303,66 @@ func listLibsCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string)
 	return nil
 }
 
-func showProgress(ch <- chan progressio.Progress, local, lib, remote string) {
+func mkLibCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return fmt.Errorf("Useage: mklib <library name> [description] [password]")
+	}
+	var desc, pass string
+	if len(args) > 1 {
+		desc = args[1]
+	}
+	if len(args) > 2 {
+		pass = args[2]
+	}
+	log.Printf("# mklib '%s'\n", args[0])
+	if l, err := sf.CreateLibraryContext(ctx, args[0], desc, pass); err != nil {
+		return err
+	} else {
+		log.Printf("# mklib created '%s' (%s)\n", l.Name, l.Id)
+	}
+	return nil
+}
+
+func rmLibCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: rmlib <library name>")
+	}
+	log.Printf("# rmlib '%s'\n", args[0])
+	if l, err := getLibrary(ctx, sf, conf, args[0]); err != nil {
+		return err
+	} else if err := l.DeleteContext(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func renameLibCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Useage: renamelib <library name> <new library name>")
+	}
+	log.Printf("# renamelib '%s' => '%s'\n", args[0], args[1])
+	if l, err := getLibrary(ctx, sf, conf, args[0]); err != nil {
+		return err
+	} else if err := l.RenameContext(ctx, args[1]); err != nil {
+		return err
+	}
+	// Keep working in the same library
+	if conf.Library == args[0] {
+		conf.Library = args[1]
+	}
+	return nil
+}
+
+func showProgress(ch <- chan progressio.Progress, src, dst string) {
 	clearstr := ""
 	//ss := Metric
 	ss := progressio.IEC
 	p := progressio.Progress{}
 	for p = range ch {
-		str := fmt.Sprintf("[%.2f%%] %s => %s::%s (%s/%s) (Speed: %s/sec, AVG: %s/sec) (Remaining: %s)",
+		str := fmt.Sprintf("[%.2f%%] %s => %s (%s/%s) (Speed: %s/sec, AVG: %s/sec) (Remaining: %s)",
 			p.Percent,
-			local,
-			lib,
-			remote,
+			src,
+			dst,
 			progressio.FormatSize(ss, p.Transferred, true),
 			progressio.FormatSize(ss, p.TotalSize, true),
 			progressio.FormatSize(ss, p.Speed, true),
@@ -116,23 +375,554 @@ func showProgress(ch <- chan progressio.Progress, local, lib, remote string) {
 		fmt.Printf("%s\r", clearstr)
 		fmt.Printf("%s\r", str)
 	}
-	fmt.Printf("%s\r[DONE] %s => %s::%s (Size: %s, Time: %s, Speed: %s/sec) \n",
+	fmt.Printf("%s\r[DONE] %s => %s (Size: %s, Time: %s, Speed: %s/sec) \n",
 		clearstr,
-		local,
-		lib,
-		remote,
+		src,
+		dst,
 		progressio.FormatSize(ss, p.TotalSize, true),
 		progressio.FormatDuration(time.Since(p.StartTime)),
 		progressio.FormatSize(ss, p.SpeedAvg, true),
 	)
 }
 
-func uploadCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
-	if l, err := sf.GetLibrary(conf.Library); err != nil {
+// parentsFlag strips a leading "-p" option from the arguments, returning
+// whether it was present.
+func parentsFlag(args []string) (bool, []string) {
+	if len(args) > 0 && args[0] == "-p" {
+		return true, args[1:]
+	}
+	return false, args
+}
+
+func mkdirCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	parents, args := parentsFlag(args)
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: mkdir [-p] <remote directory>")
+	} else if l, err := getLibrary(ctx, sf, conf, conf.Library); err != nil {
+		return err
+	} else {
+		log.Printf("# mkdir '%s::%s'\n", conf.Library, args[0])
+		return l.MkdirContext(ctx, args[0], parents)
+	}
+}
+
+func rmdirCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: rmdir <remote directory>")
+	} else if l, err := getLibrary(ctx, sf, conf, conf.Library); err != nil {
+		return err
+	} else {
+		log.Printf("# rmdir '%s::%s'\n", conf.Library, args[0])
+		return l.RemoveDirContext(ctx, args[0])
+	}
+}
+
+// remotePath splits a "library::path" argument in the library and the path.
+// If no library is given, the current library is used.
+func remotePath(ctx context.Context, sf *goseafile.SeaFile, conf *Config, arg string) (*goseafile.Library, string, error) {
+	lib := conf.Library
+	if i := strings.Index(arg, "::"); i >= 0 {
+		lib = arg[:i]
+		arg = arg[i+2:]
+	}
+	if l, err := getLibrary(ctx, sf, conf, lib); err != nil {
+		return nil, "", err
+	} else {
+		return l, arg, nil
+	}
+}
+
+// groupByDir groups the given paths by their parent directory
+func groupByDir(paths []string) (dirs []string, names map[string][]string) {
+	names = make(map[string][]string)
+	for _, p := range paths {
+		p = path.Clean("/" + p)
+		dir := path.Dir(p)
+		if _, ok := names[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		names[dir] = append(names[dir], path.Base(p))
+	}
+	return dirs, names
+}
+
+func mvCpCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("Useage: %s <source>... <[library::]destination>", cmd)
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	dl, dst, err := remotePath(ctx, sf, conf, args[len(args)-1])
+	if err != nil {
+		return err
+	}
+	srcs := args[:len(args)-1]
+	log.Printf("# %s '%s' => '%s::%s'\n", cmd, strings.Join(srcs, "', '"), dl.Name, dst)
+
+	if strings.HasSuffix(dst, "/") {
+		// Move or copy everything into the destination directory
+		dirs, names := groupByDir(srcs)
+		for _, dir := range dirs {
+			if cmd == "mv" {
+				err = l.MoveFilesContext(ctx, dir, names[dir], dl, dst)
+			} else {
+				err = l.CopyFilesContext(ctx, dir, names[dir], dl, dst)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	} else if len(srcs) > 1 {
+		return fmt.Errorf("%s: destination must be a directory ending in '/' when specifying multiple sources", cmd)
+	}
+
+	// Single file to a destination file name
+	src := path.Clean("/" + srcs[0])
+	dst = path.Clean("/" + dst)
+	if dl.Id == l.Id && path.Dir(src) == path.Dir(dst) {
+		if cmd == "cp" {
+			return fmt.Errorf("cp: cannot copy a file within the same directory")
+		}
+		return l.RenameFileContext(ctx, src, path.Base(dst))
+	}
+	moved := path.Join(path.Dir(dst), path.Base(src))
+	if path.Base(src) != path.Base(dst) {
+		// The file is renamed after moving it. On a name conflict the server
+		// picks another name, and the existing file would be renamed instead.
+		if _, err := dl.StatContext(ctx, moved); err == nil {
+			return fmt.Errorf("%s: '%s' already exists in '%s::%s'", cmd, path.Base(src), dl.Name, path.Dir(dst))
+		} else if !errors.Is(err, goseafile.NotFoundError) {
+			return err
+		}
+	}
+	if cmd == "mv" {
+		err = l.MoveFileContext(ctx, src, dl, path.Dir(dst))
+	} else {
+		err = l.CopyFileContext(ctx, src, dl, path.Dir(dst))
+	}
+	if err != nil {
+		return err
+	}
+	if path.Base(src) != path.Base(dst) {
+		return dl.RenameFileContext(ctx, moved, path.Base(dst))
+	}
+	return nil
+}
+
+func rmCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Useage: rm <path>...")
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	log.Printf("# rm '%s::%s'\n", conf.Library, strings.Join(args, "', '"))
+	dirs, names := groupByDir(args)
+	for _, dir := range dirs {
+		if err := l.DeleteFilesContext(ctx, dir, names[dir]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shareCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return fmt.Errorf("Useage: share <path> [password] [expire days]")
+	}
+	var pass string
+	var days int
+	if len(args) > 1 {
+		pass = args[1]
+	}
+	if len(args) > 2 {
+		if d, err := strconv.Atoi(args[2]); err != nil || d < 0 {
+			return fmt.Errorf("share: invalid number of days '%s'", args[2])
+		} else {
+			days = d
+		}
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	log.Printf("# share '%s::%s'\n", conf.Library, args[0])
+	if sl, err := l.CreateShareLinkContext(ctx, args[0], pass, days); err != nil {
+		return err
+	} else {
+		log.Printf("%s\n", sl.Link)
+	}
+	return nil
+}
+
+func listSharesCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Useage: listshares [path]")
+	}
+	var p string
+	if len(args) == 1 {
+		p = args[0]
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	if v, err := l.ListShareLinksContext(ctx, p); err != nil {
+		return err
+	} else {
+		log.Printf("# listshares start\n")
+		for _, sl := range v {
+			expires := "never"
+			if sl.ExpireDate != "" {
+				expires = sl.ExpireDate
+			}
+			log.Printf("%s %s %s views:%d expires:%s\n", sl.Token, sl.Path, sl.Link, sl.ViewCount, expires)
+		}
+		log.Printf("# listshares end\n")
+	}
+	return nil
+}
+
+func rmShareCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: rmshare <token>")
+	}
+	log.Printf("# rmshare '%s'\n", args[0])
+	return sf.DeleteShareLinkContext(ctx, args[0])
+}
+
+func uploadLinkCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return fmt.Errorf("Useage: uploadlink <directory> [password] [expire days]")
+	}
+	var pass string
+	var days int
+	if len(args) > 1 {
+		pass = args[1]
+	}
+	if len(args) > 2 {
+		if d, err := strconv.Atoi(args[2]); err != nil || d < 0 {
+			return fmt.Errorf("uploadlink: invalid number of days '%s'", args[2])
+		} else {
+			days = d
+		}
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	log.Printf("# uploadlink '%s::%s'\n", conf.Library, args[0])
+	if ul, err := l.CreateUploadLinkContext(ctx, args[0], pass, days); err != nil {
+		return err
+	} else {
+		log.Printf("%s\n", ul.Link)
+	}
+	return nil
+}
+
+func listUploadLinksCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Useage: listuploadlinks [directory]")
+	}
+	var p string
+	if len(args) == 1 {
+		p = args[0]
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	if v, err := l.ListUploadLinksContext(ctx, p); err != nil {
+		return err
+	} else {
+		log.Printf("# listuploadlinks start\n")
+		for _, ul := range v {
+			expires := "never"
+			if ul.ExpireDate != "" {
+				expires = ul.ExpireDate
+			}
+			log.Printf("%s %s %s views:%d expires:%s\n", ul.Token, ul.Path, ul.Link, ul.ViewCount, expires)
+		}
+		log.Printf("# listuploadlinks end\n")
+	}
+	return nil
+}
+
+func rmUploadLinkCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: rmuploadlink <token>")
+	}
+	log.Printf("# rmuploadlink '%s'\n", args[0])
+	return sf.DeleteUploadLinkContext(ctx, args[0])
+}
+
+func linkUploadCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return fmt.Errorf("Useage: linkupload <upload link> <source file> [password] [link directory]")
+	}
+	var pass string
+	if len(args) > 2 {
+		pass = args[2]
+	}
+	c, err := sf.OpenUploadLinkContext(ctx, args[0], pass)
+	if err != nil {
+		return err
+	}
+	if len(args) > 3 {
+		c.Dir = args[3]
+	}
+	local := args[1]
+	log.Printf("# linkupload '%s' => '%s'\n", local, args[0])
+	if f, ch, err := progressio.NewProgressFileReader(local); err != nil {
+		return err
+	} else {
+		defer f.Close()
+		go showProgress(ch, local, args[0])
+		return c.UploadContext(ctx, f, filepath.Base(local))
+	}
+}
+
+func shareUserCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		if cmd == "sharegroup" {
+			return fmt.Errorf("Useage: sharegroup <group> <r|rw|admin> [directory]")
+		}
+		return fmt.Errorf("Useage: shareuser <email> <r|rw|admin> [directory]")
+	}
+	perm, err := goseafile.ParsePermission(args[1])
+	if err != nil {
+		return err
+	}
+	dir := "/"
+	if len(args) > 2 {
+		dir = args[2]
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	log.Printf("# %s '%s::%s' => '%s' (%s)\n", cmd, conf.Library, dir, args[0], perm)
+	if cmd == "sharegroup" {
+		if g, err := getGroup(ctx, sf, args[0]); err != nil {
+			return err
+		} else {
+			return l.ShareDirWithGroupContext(ctx, dir, g.Id, perm)
+		}
+	}
+	return l.ShareDirWithUserContext(ctx, dir, args[0], perm)
+}
+
+func unshareCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		if cmd == "unsharegroup" {
+			return fmt.Errorf("Useage: unsharegroup <group> [directory]")
+		}
+		return fmt.Errorf("Useage: unshareuser <email> [directory]")
+	}
+	sh := &goseafile.Share{
+		ShareType: goseafile.ShareTypeUser,
+		User:      args[0],
+		Path:      "/",
+	}
+	if cmd == "unsharegroup" {
+		if g, err := getGroup(ctx, sf, args[0]); err != nil {
+			return err
+		} else {
+			sh = &goseafile.Share{
+				ShareType: goseafile.ShareTypeGroup,
+				GroupId:   g.Id,
+				Path:      "/",
+			}
+		}
+	}
+	if len(args) > 1 {
+		sh.Path = args[1]
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	log.Printf("# %s '%s::%s' => '%s'\n", cmd, conf.Library, sh.Path, args[0])
+	return l.UnshareContext(ctx, sh)
+}
+
+func listLibSharesCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Useage: listlibshares [directory]")
+	}
+	dir := "/"
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	l, err := getLibrary(ctx, sf, conf, conf.Library)
+	if err != nil {
+		return err
+	}
+	if v, err := l.ListDirSharesContext(ctx, dir); err != nil {
+		return err
+	} else {
+		log.Printf("# listlibshares start\n")
+		for _, sh := range v {
+			if sh.ShareType == goseafile.ShareTypeGroup {
+				log.Printf("group %d (%s) %s\n", sh.GroupId, sh.GroupName, sh.Permission)
+			} else {
+				log.Printf("user %s (%s) %s\n", sh.User, sh.UserName, sh.Permission)
+			}
+		}
+		log.Printf("# listlibshares end\n")
+	}
+	return nil
+}
+
+// getGroup returns the group with the given name or numeric id
+func getGroup(ctx context.Context, sf *goseafile.SeaFile, group string) (*goseafile.Group, error) {
+	id, err := strconv.Atoi(group)
+	if err != nil {
+		return sf.GetGroupContext(ctx, group)
+	}
+	if groups, err := sf.ListGroupsContext(ctx); err != nil {
+		return nil, err
+	} else {
+		for _, g := range groups {
+			if g.Id == id {
+				return g, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find group %d", id)
+}
+
+func listGroupsCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Useage: listgroups")
+	}
+	if v, err := sf.ListGroupsContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("# listgroups start\n")
+		for _, g := range v {
+			log.Printf("%d %s owner:%s\n", g.Id, g.Name, g.Owner)
+		}
+		log.Printf("# listgroups end\n")
+	}
+	return nil
+}
+
+func mkGroupCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: mkgroup <group name>")
+	}
+	log.Printf("# mkgroup '%s'\n", args[0])
+	if g, err := sf.CreateGroupContext(ctx, args[0]); err != nil {
+		return err
+	} else {
+		log.Printf("# mkgroup created '%s' (%d)\n", g.Name, g.Id)
+	}
+	return nil
+}
+
+func rmGroupCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: rmgroup <group>")
+	}
+	log.Printf("# rmgroup '%s'\n", args[0])
+	if g, err := getGroup(ctx, sf, args[0]); err != nil {
+		return err
+	} else {
+		return g.DeleteContext(ctx)
+	}
+}
+
+func listMembersCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: listmembers <group>")
+	}
+	g, err := getGroup(ctx, sf, args[0])
+	if err != nil {
+		return err
+	}
+	if v, err := g.ListMembersContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("# listmembers start\n")
+		for _, m := range v {
+			log.Printf("%s (%s) %s\n", m.Email, m.Name, m.Role)
+		}
+		log.Printf("# listmembers end\n")
+	}
+	return nil
+}
+
+func memberCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Useage: %s <group> <email>", cmd)
+	}
+	g, err := getGroup(ctx, sf, args[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("# %s '%s' => '%s'\n", cmd, args[1], g.Name)
+	if cmd == "rmmember" {
+		return g.RemoveMemberContext(ctx, args[1])
+	}
+	return g.AddMemberContext(ctx, args[1])
+}
+
+func listGroupLibsCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Useage: listgrouplibs <group>")
+	}
+	g, err := getGroup(ctx, sf, args[0])
+	if err != nil {
+		return err
+	}
+	if v, err := g.ListLibrariesContext(ctx); err != nil {
+		return err
+	} else {
+		log.Printf("# listgrouplibs start\n")
+		for _, l := range v {
+			log.Printf("%s\n", l.Name)
+		}
+		log.Printf("# listgrouplibs end\n")
+	}
+	return nil
+}
+
+func mkGroupLibCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		return fmt.Errorf("Useage: mkgrouplib <group> <library name> [description] [password]")
+	}
+	var desc, pass string
+	if len(args) > 2 {
+		desc = args[2]
+	}
+	if len(args) > 3 {
+		pass = args[3]
+	}
+	g, err := getGroup(ctx, sf, args[0])
+	if err != nil {
+		return err
+	}
+	log.Printf("# mkgrouplib '%s' => '%s'\n", args[1], g.Name)
+	if l, err := g.CreateLibraryContext(ctx, args[1], desc, pass); err != nil {
+		return err
+	} else {
+		log.Printf("# mkgrouplib created '%s' (%s)\n", l.Name, l.Id)
+	}
+	return nil
+}
+
+func uploadCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	parents, args := parentsFlag(args)
+	if l, err := getLibrary(ctx, sf, conf, conf.Library); err != nil {
 		return err
 	} else if len(args) < 1 || len(args) > 2 {
 		// Print help
-		return fmt.Errorf("Useage: upload <source file> [remote destination file]")
+		return fmt.Errorf("Useage: upload [-p] <source file> [remote destination file]")
 	} else {
 		var local, remote string
 		
@@ -147,14 +937,20 @@ func uploadCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) e
 			remote = path.Clean(remote)
 		}
 
+		if parents {
+			if err := l.MkdirContext(ctx, path.Dir(remote), true); err != nil {
+				return err
+			}
+		}
+
 		log.Printf("# Upload '%s' => '%s::%s'\n", local, conf.Library, remote)
 		if f, ch, err := progressio.NewProgressFileReader(local); err != nil {
 			return err
 		} else {
 			defer f.Close()
-			go showProgress(ch, local, conf.Library, remote)
+			go showProgress(ch, local, conf.Library+"::"+remote)
 		
-			if err := l.Upload(f, remote); err != nil {
+			if err := l.UploadContext(ctx, f, remote); err != nil {
 				return err
 			}
 		}
@@ -162,19 +958,142 @@ func uploadCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) e
 	return nil
 }
 
-func downloadCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
-	return fmt.Errorf("Not implemented")
+func downloadCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if l, err := getLibrary(ctx, sf, conf, conf.Library); err != nil {
+		return err
+	} else if len(args) < 1 || len(args) > 2 {
+		// Print help
+		return fmt.Errorf("Useage: download <remote file> [local destination file]")
+	} else {
+		var local, remote string
+
+		remote = path.Clean("/" + args[0])
+		if len(args) == 1 {
+			local = path.Base(remote)
+		} else {
+			local = args[1]
+			if fi, err := os.Stat(local); strings.HasSuffix(local, "/") || (err == nil && fi.IsDir()) {
+				local = filepath.Join(local, path.Base(remote))
+			}
+		}
+
+		log.Printf("# Download '%s::%s' => '%s'\n", conf.Library, remote, local)
+		rf, err := l.OpenFileContext(ctx, remote)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+
+		// Download to a .part file, which is renamed when complete. An
+		// interrupted download is resumed from the existing .part file.
+		part := local + ".part"
+		var offset int64
+		if fi, err := os.Stat(part); err == nil && fi.Size() <= rf.Size() {
+			offset = fi.Size()
+		}
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if offset > 0 {
+			log.Printf("# Resuming download at %d bytes\n", offset)
+			flags = os.O_WRONLY | os.O_APPEND
+			if _, err := rf.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		if f, err := os.OpenFile(part, flags, 0644); err != nil {
+			return err
+		} else {
+			defer f.Close()
+			pw, ch := progressio.NewProgressWriter(f, rf.Size()-offset)
+			defer pw.Close()
+			go showProgress(ch, conf.Library+"::"+remote, local)
+
+			if _, err := io.Copy(pw, rf); err != nil {
+				return err
+			} else if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		return os.Rename(part, local)
+	}
+}
+
+func fetchCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return fmt.Errorf("Useage: fetch <share link> [local destination] [password]")
+	}
+	var pass string
+	if len(args) > 2 {
+		pass = args[2]
+	}
+	psl, err := sf.OpenShareLinkContext(ctx, args[0], pass)
+	if err != nil {
+		return err
+	}
+	var f *goseafile.ShareLinkFile
+	if psl.IsDir {
+		// Directory links are fetched as a zip file, a subdirectory can be
+		// selected with ?p=<path> in the link
+		var dir string
+		if u, err := url.Parse(args[0]); err == nil {
+			dir = u.Query().Get("p")
+		}
+		f, err = psl.OpenZipContext(ctx, dir)
+	} else {
+		f, err = psl.OpenContext(ctx, "")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := filepath.Base(f.Name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = psl.Token
+	}
+	local := name
+	if len(args) > 1 && args[1] != "" {
+		local = args[1]
+		if fi, err := os.Stat(local); strings.HasSuffix(local, "/") || (err == nil && fi.IsDir()) {
+			local = filepath.Join(local, name)
+		}
+	}
+	log.Printf("# fetch '%s' => '%s'\n", args[0], local)
+	// Download to a temporary file first, so a failed download doesn't leave
+	// a truncated file behind
+	lf, err := ioutil.TempFile(filepath.Dir(local), "."+filepath.Base(local)+".*.part")
+	if err != nil {
+		return err
+	}
+	tmp := lf.Name()
+	defer os.Remove(tmp)
+	defer lf.Close()
+	var w io.Writer = lf
+	if f.Size > 0 {
+		pw, ch := progressio.NewProgressWriter(lf, f.Size)
+		defer pw.Close()
+		go showProgress(ch, args[0], local)
+		w = pw
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	} else if err := lf.Chmod(0644); err != nil {
+		return err
+	} else if err := lf.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, local)
 }
 
-func listCmd(cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
-	if l, err := sf.GetLibrary(conf.Library); err != nil {
+func listCmd(ctx context.Context, cmd string, sf *goseafile.SeaFile, conf *Config, args []string) error {
+	if l, err := getLibrary(ctx, sf, conf, conf.Library); err != nil {
 		return err
 	} else {
 		arg := ""
 		if len(args) > 0 {
 			arg = args[0]
 		}
-		if fl, err := l.List(arg); err != nil {
+		if fl, err := l.ListContext(ctx, arg); err != nil {
 			return err
 		} else {
 			log.Printf("# list start { \"lib\": \"%s\", \"path\": \"%s\" }\n", conf.Library, arg)
@@ -221,14 +1140,14 @@ func (c *Command) GetCmds() []string {
 	return r
 }
 
-func (c *Command) Run(sf *goseafile.SeaFile, conf *Config, args []string) error {
+func (c *Command) Run(ctx context.Context, sf *goseafile.SeaFile, conf *Config, args []string) error {
 	if f, ok := cmdList[c.Cmd]; ok {
-		return f(c.Cmd, sf, conf, args)
+		return f(ctx, c.Cmd, sf, conf, args)
 	}
 	return fmt.Errorf("unknown command %s", c.Cmd)
 }
 
-func runScript(sf *goseafile.SeaFile, conf *Config, stream io.Reader, args ...string) error {
+func runScript(ctx context.Context, sf *goseafile.SeaFile, conf *Config, stream io.Reader, args ...string) error {
 	rd := bufio.NewScanner(stream)
 	// Prepare env: add arguments as $1, $2, ...
 	for cnt, argv := range args {
@@ -236,6 +1155,9 @@ func runScript(sf *goseafile.SeaFile, conf *Config, stream io.Reader, args ...st
 	}
 	ln := 0
 	for rd.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		cmdstring := strings.TrimSpace(rd.Text())
 		ln++
 		if strings.HasPrefix(cmdstring, "#") {
@@ -260,7 +1182,7 @@ func runScript(sf *goseafile.SeaFile, conf *Config, stream io.Reader, args ...st
 		if err := cmd.Set(cmdsplit[0]); err != nil {
 			log.Fatalf("[ERROR] Script line %d: '%s': %s", ln, cmdstring, err)
 		}
-		if err := cmd.Run(sf, conf, args); err != nil {
+		if err := cmd.Run(ctx, sf, conf, args); err != nil {
 			log.Fatalf("[ERROR] Script line %d: '%s': %s", ln, cmdstring, err)
 		}
 	}
@@ -271,11 +1193,23 @@ func runScript(sf *goseafile.SeaFile, conf *Config, stream io.Reader, args ...st
 	return nil
 }
 
+// promptOTP asks the user for a two factor authentication code on the
+// terminal.
+func promptOTP(ctx context.Context) (string, error) {
+	fmt.Fprint(os.Stderr, "Two factor authentication code: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
 /////////////////////////////////////////////////////////////////////////////
 
 func main() {
 	var conf, cmdconf Config
-	var conffile string
+	var conffile, libPass, otp string
+	var retries int
 	var cmd Command
 	var logDebug, logWarn bool
 	
@@ -292,13 +1226,45 @@ func main() {
 	flag.StringVar(&conf.Url, "url", "", "the API endpoint")
 	flag.StringVar(&conf.User, "user", "", "the user")
 	flag.StringVar(&conf.Password, "password", "", "the user's password")
-	flag.StringVar(&conf.AuthToken, "token", "", "a valid auth token")
+	flag.StringVar(&conf.AuthToken, "token", "", "a valid auth token, used instead of logging in with the password")
 	flag.StringVar(&conf.Library, "lib", "My Library", "the library to work in")
 	flag.StringVar(&conf.Script, "script", "", "A script to execute.")
+	flag.StringVar(&otp, "otp", "", "the two factor authentication code, if the server requires one")
+	flag.StringVar(&libPass, "libpass", "", "the password of the library when it is encrypted")
+	flag.StringVar(&conf.CAFile, "cafile", "", "a PEM file with additional CA certificates to trust")
+	flag.BoolVar(&conf.Insecure, "insecure", false, "don't verify the server certificate")
+	flag.StringVar(&conf.CertFile, "cert", "", "a PEM file with the client certificate")
+	flag.StringVar(&conf.KeyFile, "key", "", "a PEM file with the client certificate key")
+	flag.StringVar(&conf.Proxy, "proxy", "", "the URL of the proxy to use")
+	flag.StringVar(&conf.FileServer, "fileserver", "", "the URL of the fileserver, if not the server URL followed by /seafhttp")
+	flag.StringVar(&conf.Timeout, "timeout", "", "the connect and response timeout, e.g. 30s")
+	flag.IntVar(&retries, "retries", 3, "the number of times a throttled or failed request is retried")
+	flag.StringVar(&conf.TokenFile, "tokenfile", "", "the file to cache authentication tokens in (default ~/.config/goseafile/tokens.json)")
+	flag.StringVar(&conf.TokenTTL, "tokenttl", "", "how long a cached token is used, e.g. 12h, or until-rejected (default 30m)")
 	flag.BoolVar(&logDebug, "debug", false, "Output warning & debug statements.")
 	flag.BoolVar(&logWarn, "warn", false, "Output warnings")
 	flag.Var(&cmd, "cmd", "the command to execute. Available commands are: "+strings.Join(cmd.GetCmds(), ", "))
 	flag.Parse()
+	conf.Retries = &retries
+
+	// Environment variables are used for the options not given on the
+	// commandline
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	for _, ev := range []struct {
+		flag, env string
+		val       *string
+	}{
+		{"url", "SEAFILE_URL", &conf.Url},
+		{"user", "SEAFILE_USER", &conf.User},
+		{"password", "SEAFILE_PASSWORD", &conf.Password},
+		{"token", "SEAFILE_TOKEN", &conf.AuthToken},
+		{"lib", "SEAFILE_LIBRARY", &conf.Library},
+	} {
+		if v := os.Getenv(ev.env); v != "" && !setFlags[ev.flag] {
+			*ev.val = v
+		}
+	}
 	
 	if logWarn {
 		filter.MinLevel = "WARN"
@@ -324,22 +1290,125 @@ func main() {
 		if cmdconf.Password != "" {
 			conf.Password = cmdconf.Password
 		}
+		if cmdconf.AuthToken != "" {
+			conf.AuthToken = cmdconf.AuthToken
+		}
 		if cmdconf.Library != "" {
 			conf.Library = cmdconf.Library
 		}
+		if cmdconf.Script != "" {
+			conf.Script = cmdconf.Script
+		}
+		conf.LibPasswords = cmdconf.LibPasswords
+		if cmdconf.CAFile != "" {
+			conf.CAFile = cmdconf.CAFile
+		}
+		if cmdconf.Insecure {
+			conf.Insecure = cmdconf.Insecure
+		}
+		if cmdconf.CertFile != "" {
+			conf.CertFile = cmdconf.CertFile
+		}
+		if cmdconf.KeyFile != "" {
+			conf.KeyFile = cmdconf.KeyFile
+		}
+		if cmdconf.Proxy != "" {
+			conf.Proxy = cmdconf.Proxy
+		}
+		if cmdconf.FileServer != "" {
+			conf.FileServer = cmdconf.FileServer
+		}
+		if cmdconf.Timeout != "" {
+			conf.Timeout = cmdconf.Timeout
+		}
+		if cmdconf.Retries != nil {
+			conf.Retries = cmdconf.Retries
+		}
+		if cmdconf.TokenFile != "" {
+			conf.TokenFile = cmdconf.TokenFile
+		}
+		if cmdconf.TokenTTL != "" {
+			conf.TokenTTL = cmdconf.TokenTTL
+		}
+	}
+	if libPass != "" {
+		if conf.LibPasswords == nil {
+			conf.LibPasswords = make(map[string]string)
+		}
+		conf.LibPasswords[conf.Library] = libPass
 	}
 	if (verMaj != "") && (verMin != "") {
 		log.Printf("goseafile-cli v%s.%s git:%s date:%s\n", verMaj, verMin, buildHash, buildDate)
 	} else {
 		log.Printf("goseafile-cli <experimental build>\n")
 	}
+	// Cancel running requests on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cc := goseafile.ClientConfig{
+		CAFile:   conf.CAFile,
+		Insecure: conf.Insecure,
+		CertFile: conf.CertFile,
+		KeyFile:  conf.KeyFile,
+		Proxy:    conf.Proxy,
+	}
+	if conf.Timeout != "" {
+		if d, err := time.ParseDuration(conf.Timeout); err != nil {
+			log.Fatalf("[ERROR] Invalid timeout '%s': %s\n", conf.Timeout, err)
+		} else {
+			cc.Timeout = d
+		}
+	}
+	client, err := goseafile.NewHTTPClient(cc)
+	if err != nil {
+		log.Fatalf("[ERROR] Could not set up the HTTP client: %s\n", err)
+	}
 	sf := &goseafile.SeaFile{
 		Url: conf.Url,
 		User: conf.User,
 		Password: conf.Password,
+		AuthToken: conf.AuthToken,
+		HTTPClient: client,
+		// Keep the [LEVEL] prefixed output, so it goes through the level filter
+		Logger: goseafile.NewStdLogger(nil),
+		Retry: &goseafile.RetryPolicy{
+			MaxAttempts: *conf.Retries + 1,
+		},
+		SaveAuth: true,
+		OTP: otp,
+		FileServerUrl: conf.FileServer,
+	}
+	if conf.Script != "-" {
+		// Ask for the two factor authentication code when needed
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			sf.OTPFunc = promptOTP
+		}
+	}
+	if conf.TokenTTL == "until-rejected" {
+		sf.TokenTTL = goseafile.TokenUntilRejected
+	} else if conf.TokenTTL != "" {
+		if d, err := time.ParseDuration(conf.TokenTTL); err != nil || d <= 0 {
+			log.Fatalf("[ERROR] Invalid token ttl '%s', expected a duration or until-rejected\n", conf.TokenTTL)
+		} else {
+			sf.TokenTTL = d
+		}
+	}
+	store := &goseafile.FileTokenStore{
+		Path:   conf.TokenFile,
+		MaxAge: 30 * time.Minute,
+		Logger: sf.Logger,
+	}
+	if sf.TokenTTL != 0 {
+		// Keep the tokens as long as they can be used
+		store.MaxAge = sf.TokenTTL
+		if store.MaxAge < 0 {
+			store.MaxAge = 0
+		}
 	}
+	sf.TokenStore = store
 	if conf.Script == "-" {
-		if err := runScript(sf, &conf, os.Stdin, flag.Args()...); err != nil {
+		if err := runScript(ctx, sf, &conf, os.Stdin, flag.Args()...); err != nil {
 			log.Fatalf("[ERROR] Script error: %s\n", err)
 		}
 	} else if conf.Script != "" {
@@ -348,11 +1417,11 @@ func main() {
 			log.Fatalf("[ERROR] Could not open script '%s': %s\n", conf.Script, err)
 		} else {
 			defer file.Close()
-			if err := runScript(sf, &conf, file, flag.Args()...); err != nil {
+			if err := runScript(ctx, sf, &conf, file, flag.Args()...); err != nil {
 				log.Fatalf("[ERROR] Script error: %s\n", err)
 			}
 		}
-	} else if err := cmd.Run(sf, &conf, flag.Args()); err != nil {
+	} else if err := cmd.Run(ctx, sf, &conf, flag.Args()); err != nil {
 		log.Fatalf("[ERROR] Command %s returned an error: %s\n", cmd.String(), err)
 	}
 }
@@ -1,11 +1,198 @@
 package goseafile
 
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+)
+
 // File represents a file in a SeaFile library
 type File struct {
 	lib   *Library `json:"-"`
+	dir   string   `json:"-"`
 	Id    string
 	Mtime int64
 	Type  string
 	Name  string
 	Size  int64
 }
+
+// Path returns the full path of the file in its library
+func (f *File) Path() string {
+	return path.Join(f.dir, f.Name)
+}
+
+// Open opens the file for reading, returning a RemoteFile which supports
+// seeking and random access reads.
+func (f *File) Open() (*RemoteFile, error) {
+	return f.OpenContext(context.Background())
+}
+
+// OpenContext is like Open, using the given context for the requests.
+func (f *File) OpenContext(ctx context.Context) (*RemoteFile, error) {
+	if f.lib == nil {
+		return nil, fmt.Errorf("file '%s' is not linked to a library", f.Name)
+	}
+	if f.Type != "" && f.Type != "file" {
+		return nil, fmt.Errorf("'%s' is not a file", f.Path())
+	}
+	if link, err := f.lib.downloadLink(ctx, f.Path(), true); err != nil {
+		return nil, err
+	} else {
+		return &RemoteFile{
+			ctx:  ctx,
+			lib:  f.lib,
+			link: link,
+			size: f.Size,
+		}, nil
+	}
+}
+
+// RemoteFile gives access to the content of a file on the SeaFile server.
+// It implements io.ReadSeeker and io.ReaderAt using HTTP Range requests, so
+// partial or interrupted downloads can be resumed. All requests use the
+// context the file was opened with.
+type RemoteFile struct {
+	ctx    context.Context
+	lib    *Library
+	link   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// Size returns the size of the remote file
+func (rf *RemoteFile) Size() int64 {
+	return rf.size
+}
+
+// rangeReq requests the byte range [off, end] of the remote file. If end is
+// negative, everything from off to the end of the file is requested.
+func (rf *RemoteFile) rangeReq(off, end int64) (io.ReadCloser, error) {
+	req, err := rf.lib.sf.newReq(rf.ctx, "GET", rf.link)
+	if err != nil {
+		return nil, err
+	}
+	if end < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", off))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end))
+	}
+	resp, err := rf.lib.sf.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// A server ignoring the Range header answers with the full content, which
+	// is only usable when we wanted to start at the beginning anyway.
+	if resp.StatusCode == 200 {
+		if off == 0 {
+			return resp.Body, nil
+		}
+		resp.Body.Close()
+		return nil, fmt.Errorf("server does not support range requests")
+	} else if err := rf.lib.sf.checkResponse(resp, 206); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// maxReconnects is the number of times a Read reconnects without receiving
+// any data, when the connection is closed before the end of the file.
+const maxReconnects = 3
+
+// Read reads from the current offset in the remote file. When the
+// connection is closed before the end of the file, it reconnects and
+// continues at the current offset.
+func (rf *RemoteFile) Read(p []byte) (int, error) {
+	if rf.offset >= rf.size {
+		return 0, io.EOF
+	}
+	for attempt := 1; ; attempt++ {
+		if rf.body == nil {
+			if body, err := rf.rangeReq(rf.offset, -1); err != nil {
+				return 0, err
+			} else {
+				rf.body = body
+			}
+		}
+		n, err := rf.body.Read(p)
+		rf.offset += int64(n)
+		if (err == io.EOF || err == io.ErrUnexpectedEOF) && rf.offset < rf.size {
+			// Connection was closed early, reconnect at the current offset
+			rf.body.Close()
+			rf.body = nil
+			if n > 0 {
+				return n, nil
+			} else if attempt > maxReconnects {
+				return 0, io.ErrUnexpectedEOF
+			}
+			rf.lib.sf.logger().Warn("Connection closed early, reconnecting", "offset", rf.offset, "size", rf.size, "attempt", attempt)
+			continue
+		}
+		return n, err
+	}
+}
+
+// Seek sets the offset for the next Read
+func (rf *RemoteFile) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = rf.offset + offset
+	case io.SeekEnd:
+		abs = rf.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position: %d", abs)
+	}
+	if abs != rf.offset && rf.body != nil {
+		rf.body.Close()
+		rf.body = nil
+	}
+	rf.offset = abs
+	return abs, nil
+}
+
+// ReadAt reads len(p) bytes starting at offset off, independent of the
+// current offset.
+func (rf *RemoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+	if off >= rf.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	end := off + int64(len(p)) - 1
+	if end >= rf.size {
+		end = rf.size - 1
+	}
+	body, err := rf.rangeReq(off, end)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:end-off+1])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes any open connection to the server
+func (rf *RemoteFile) Close() error {
+	if rf.body != nil {
+		err := rf.body.Close()
+		rf.body = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package goseafile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fileServer serves data as the file /file.bin in library r1. The fileserver
+// is handled by serve.
+func fileServer(t *testing.T, data []byte, serve http.HandlerFunc) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/repos/r1/file/detail/":
+			fmt.Fprintf(w, `{"id":"f1","name":"file.bin","type":"file","size":%d}`, len(data))
+		case "/api2/repos/r1/file/":
+			fmt.Fprintf(w, `"%s/seafhttp/files/0123456789abcdef/file.bin"`, srv.URL)
+		case "/seafhttp/files/0123456789abcdef/file.bin":
+			serve(w, r)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	return srv
+}
+
+func openRemoteFile(t *testing.T, srv *httptest.Server) *RemoteFile {
+	l := &Library{sf: &SeaFile{Url: srv.URL, AuthToken: "token"}, Id: "r1"}
+	rf, err := l.OpenFileContext(context.Background(), "/file.bin")
+	if err != nil {
+		t.Fatalf("OpenFile: %s", err)
+	}
+	return rf
+}
+
+func TestRemoteFileSeekReadAt(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 100))
+	srv := fileServer(t, data, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+	})
+	defer srv.Close()
+	rf := openRemoteFile(t, srv)
+	defer rf.Close()
+
+	if rf.Size() != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", rf.Size(), len(data))
+	}
+	seeks := []struct {
+		offset int64
+		whence int
+		want   int64
+	}{
+		{0, io.SeekStart, 0},
+		{500, io.SeekStart, 500},
+		{-100, io.SeekCurrent, 420},
+		{-10, io.SeekEnd, 990},
+	}
+	for _, s := range seeks {
+		if pos, err := rf.Seek(s.offset, s.whence); err != nil {
+			t.Fatalf("Seek(%d, %d): %s", s.offset, s.whence, err)
+		} else if pos != s.want {
+			t.Errorf("Seek(%d, %d) = %d, want %d", s.offset, s.whence, pos, s.want)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(rf, 20))
+		if err != nil {
+			t.Fatalf("Read at %d: %s", s.want, err)
+		}
+		end := s.want + 20
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		if !bytes.Equal(b, data[s.want:end]) {
+			t.Errorf("Read at %d = %q, want %q", s.want, b, data[s.want:end])
+		}
+	}
+	if _, err := rf.Seek(-1, io.SeekStart); err == nil {
+		t.Errorf("Seek to a negative position succeeded")
+	}
+
+	reads := []struct {
+		off     int64
+		n       int
+		wantN   int
+		wantErr error
+	}{
+		{0, 10, 10, nil},
+		{123, 50, 50, nil},
+		{995, 10, 5, io.EOF},
+		{1000, 10, 0, io.EOF},
+	}
+	for _, r := range reads {
+		p := make([]byte, r.n)
+		n, err := rf.ReadAt(p, r.off)
+		if n != r.wantN || err != r.wantErr {
+			t.Errorf("ReadAt(%d bytes, %d) = %d, %v, want %d, %v", r.n, r.off, n, err, r.wantN, r.wantErr)
+		} else if !bytes.Equal(p[:n], data[r.off:r.off+int64(n)]) {
+			t.Errorf("ReadAt(%d bytes, %d) = %q, want %q", r.n, r.off, p[:n], data[r.off:r.off+int64(n)])
+		}
+	}
+}
+
+func TestRemoteFileReconnect(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 100))
+	tests := []struct {
+		name string
+		// chunk is the number of bytes sent per response, failAfter the
+		// number of responses after which only empty responses are sent
+		chunk, failAfter int
+		wantErr          error
+	}{
+		{"complete", 1000, 10, nil},
+		{"cut off", 300, 10, nil},
+		{"cut off at every byte", 1, 2000, nil},
+		{"no progress", 300, 1, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := 0
+			srv := fileServer(t, data, func(w http.ResponseWriter, r *http.Request) {
+				var off int
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &off); err != nil {
+					t.Errorf("invalid range %q", r.Header.Get("Range"))
+				}
+				rest := data[off:]
+				w.Header().Set("Content-Length", fmt.Sprint(len(rest)))
+				w.WriteHeader(206)
+				responses++
+				if responses > tt.failAfter {
+					return
+				}
+				if len(rest) > tt.chunk {
+					rest = rest[:tt.chunk]
+				}
+				w.Write(rest)
+			})
+			defer srv.Close()
+			rf := openRemoteFile(t, srv)
+			defer rf.Close()
+
+			b, err := ioutil.ReadAll(rf)
+			if err != tt.wantErr {
+				t.Fatalf("ReadAll error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(b, data) {
+				t.Errorf("ReadAll returned %d bytes, want the %d bytes of the file", len(b), len(data))
+			}
+			if err != nil && responses != tt.failAfter+maxReconnects {
+				t.Errorf("%d responses, want %d", responses, tt.failAfter+maxReconnects)
+			}
+		})
+	}
+}
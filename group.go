@@ -0,0 +1,178 @@
+package goseafile
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Group represents a SeaFile group
+type Group struct {
+	sf        *SeaFile `json:"-"`
+	Id        int      `json:"id"`
+	Name      string   `json:"name"`
+	Owner     string   `json:"owner"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// GroupMember is a member of a group
+type GroupMember struct {
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	IsAdmin bool   `json:"is_admin"`
+	// Role is "Owner", "Admin" or "Member"
+	Role string `json:"role"`
+}
+
+func (g *Group) endpoint() string {
+	return apiV21 + "/groups/" + strconv.Itoa(g.Id) + "/"
+}
+
+// ListGroups returns the groups the logged in user is a member of
+func (s *SeaFile) ListGroups() ([]*Group, error) {
+	return s.ListGroupsContext(context.Background())
+}
+
+// ListGroupsContext is like ListGroups, using the given context for the requests.
+func (s *SeaFile) ListGroupsContext(ctx context.Context) ([]*Group, error) {
+	if err := s.requireVersion(ctx, "groups", "6.0"); err != nil {
+		return nil, err
+	}
+	var v []*Group
+	if err := s.req(ctx, "GET", apiV21+"/groups/", nil, &v); err != nil {
+		return nil, err
+	}
+	for _, g := range v {
+		g.sf = s
+	}
+	return v, nil
+}
+
+// GetGroup returns the group with the given name, or an error if it could
+// not be found
+func (s *SeaFile) GetGroup(name string) (*Group, error) {
+	return s.GetGroupContext(context.Background(), name)
+}
+
+// GetGroupContext is like GetGroup, using the given context for the requests.
+func (s *SeaFile) GetGroupContext(ctx context.Context, name string) (*Group, error) {
+	if groups, err := s.ListGroupsContext(ctx); err != nil {
+		return nil, err
+	} else {
+		for _, g := range groups {
+			if g.Name == name {
+				return g, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("could not find group '%s'", name)
+}
+
+// CreateGroup creates a new group owned by the logged in user
+func (s *SeaFile) CreateGroup(name string) (*Group, error) {
+	return s.CreateGroupContext(context.Background(), name)
+}
+
+// CreateGroupContext is like CreateGroup, using the given context for the requests.
+func (s *SeaFile) CreateGroupContext(ctx context.Context, name string) (*Group, error) {
+	if err := s.requireVersion(ctx, "groups", "6.0"); err != nil {
+		return nil, err
+	}
+	g := &Group{sf: s}
+	if err := s.req(ctx, "POST", apiV21+"/groups/", url.Values{"name": {name}}, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Delete deletes the group
+func (g *Group) Delete() error {
+	return g.DeleteContext(context.Background())
+}
+
+// DeleteContext is like Delete, using the given context for the requests.
+func (g *Group) DeleteContext(ctx context.Context) error {
+	return g.sf.req(ctx, "DELETE", g.endpoint(), nil, nil)
+}
+
+// ListMembers returns the members of the group
+func (g *Group) ListMembers() ([]*GroupMember, error) {
+	return g.ListMembersContext(context.Background())
+}
+
+// ListMembersContext is like ListMembers, using the given context for the requests.
+func (g *Group) ListMembersContext(ctx context.Context) ([]*GroupMember, error) {
+	var v []*GroupMember
+	if err := g.sf.req(ctx, "GET", g.endpoint()+"members/", nil, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// AddMember adds the user with the given email to the group
+func (g *Group) AddMember(email string) error {
+	return g.AddMemberContext(context.Background(), email)
+}
+
+// AddMemberContext is like AddMember, using the given context for the requests.
+func (g *Group) AddMemberContext(ctx context.Context, email string) error {
+	return g.sf.req(ctx, "POST", g.endpoint()+"members/", url.Values{"email": {email}}, nil)
+}
+
+// RemoveMember removes the user with the given email from the group
+func (g *Group) RemoveMember(email string) error {
+	return g.RemoveMemberContext(context.Background(), email)
+}
+
+// RemoveMemberContext is like RemoveMember, using the given context for the requests.
+func (g *Group) RemoveMemberContext(ctx context.Context, email string) error {
+	return g.sf.req(ctx, "DELETE", g.endpoint()+"members/"+url.PathEscape(email)+"/", nil, nil)
+}
+
+// ListLibraries returns the libraries shared with the group
+func (g *Group) ListLibraries() ([]*Library, error) {
+	return g.ListLibrariesContext(context.Background())
+}
+
+// ListLibrariesContext is like ListLibraries, using the given context for the requests.
+func (g *Group) ListLibrariesContext(ctx context.Context) ([]*Library, error) {
+	var v []*Library
+	if err := g.sf.req(ctx, "GET", "/groups/"+strconv.Itoa(g.Id)+"/repos/", nil, &v); err != nil {
+		return nil, err
+	}
+	for _, l := range v {
+		l.sf = g.sf
+		l.Type = LibraryTypeGroup
+		l.GroupId, l.GroupName = g.Id, g.Name
+	}
+	return v, nil
+}
+
+// CreateLibrary creates a new library with the given name and description,
+// which the group members can read and write. If password is not empty, an
+// encrypted library is created.
+func (g *Group) CreateLibrary(name, desc, password string) (*Library, error) {
+	return g.CreateLibraryContext(context.Background(), name, desc, password)
+}
+
+// CreateLibraryContext is like CreateLibrary, using the given context for the requests.
+func (g *Group) CreateLibraryContext(ctx context.Context, name, desc, password string) (*Library, error) {
+	var v struct {
+		Id string `json:"id"`
+	}
+	form := url.Values{
+		"name":       {name},
+		"desc":       {desc},
+		"permission": {string(PermissionReadWrite)},
+	}
+	if password != "" {
+		form.Set("passwd", password)
+	}
+	if err := g.sf.req(ctx, "POST", "/groups/"+strconv.Itoa(g.Id)+"/repos/", form, &v); err != nil {
+		return nil, err
+	} else if v.Id == "" {
+		return nil, fmt.Errorf("no library id returned for created library '%s'", name)
+	}
+	return newLibrary(ctx, g.sf, v.Id)
+}
@@ -1,13 +1,16 @@
 package goseafile
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	pathpkg "path"
 	"path/filepath"
+	"strings"
 )
 
 // Library represents a SeaFile library linked to a SeaFile instance
@@ -23,12 +26,29 @@ type Library struct {
 	Virtual    bool
 	Desc       string
 	Root       string
+	// Type is LibraryTypeOwn, LibraryTypeShared or LibraryTypeGroup
+	Type string
+	// GroupId and GroupName are set for group libraries
+	GroupId   int    `json:"groupid"`
+	GroupName string `json:"group_name"`
 }
 
+// Library types, telling how the logged in user has access to a library
+const (
+	LibraryTypeOwn    = "repo"
+	LibraryTypeShared = "srepo"
+	LibraryTypeGroup  = "grepo"
+)
+
 // GetLibrary returns the library object for a library with the given name,
 // or an error if it could not be found
 func (s *SeaFile) GetLibrary(lib string) (*Library, error) {
-	if libl, err := s.ListLibraries(); err != nil {
+	return s.GetLibraryContext(context.Background(), lib)
+}
+
+// GetLibraryContext is like GetLibrary, using the given context for the requests.
+func (s *SeaFile) GetLibraryContext(ctx context.Context, lib string) (*Library, error) {
+	if libl, err := s.ListLibrariesContext(ctx); err != nil {
 		return nil, err
 	} else {
 		for _, l := range libl {
@@ -43,8 +63,13 @@ func (s *SeaFile) GetLibrary(lib string) (*Library, error) {
 // ListLibraries returns a list with Library objects for each library available
 // for the logged in user.
 func (s *SeaFile) ListLibraries() ([]*Library, error) {
+	return s.ListLibrariesContext(context.Background())
+}
+
+// ListLibrariesContext is like ListLibraries, using the given context for the requests.
+func (s *SeaFile) ListLibrariesContext(ctx context.Context) ([]*Library, error) {
 	var v []*Library
-	if err := s.req("GET", "/repos/", nil, &v); err != nil {
+	if err := s.req(ctx, "GET", "/repos/", nil, &v); err != nil {
 		return nil, err
 	}
 	for i, _ := range v {
@@ -53,52 +78,117 @@ func (s *SeaFile) ListLibraries() ([]*Library, error) {
 	return v[0:], nil
 }
 
-func newLibrary(seafile *SeaFile, id string) *Library {
+func newLibrary(ctx context.Context, seafile *SeaFile, id string) (*Library, error) {
 	lib := &Library{
 		Id: id,
 		sf: seafile,
 	}
-	if err := lib.Update(); err != nil {
-		return nil
+	if err := lib.UpdateContext(ctx); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// CreateLibrary creates a new library with the given name and description.
+// If password is not empty, an encrypted library is created.
+func (s *SeaFile) CreateLibrary(name, desc, password string) (*Library, error) {
+	return s.CreateLibraryContext(context.Background(), name, desc, password)
+}
+
+// CreateLibraryContext is like CreateLibrary, using the given context for the requests.
+func (s *SeaFile) CreateLibraryContext(ctx context.Context, name, desc, password string) (*Library, error) {
+	var v struct {
+		RepoId string `json:"repo_id"`
+	}
+	form := url.Values{
+		"name": {name},
+		"desc": {desc},
+	}
+	if password != "" {
+		form.Set("passwd", password)
+	}
+	if err := s.req(ctx, "POST", "/repos/", form, &v); err != nil {
+		return nil, err
+	} else if v.RepoId == "" {
+		return nil, fmt.Errorf("no library id returned for created library '%s'", name)
+	}
+	return newLibrary(ctx, s, v.RepoId)
+}
+
+// Delete deletes the library
+func (l *Library) Delete() error {
+	return l.DeleteContext(context.Background())
+}
+
+// DeleteContext is like Delete, using the given context for the requests.
+func (l *Library) DeleteContext(ctx context.Context) error {
+	return l.sf.req(ctx, "DELETE", "/repos/"+l.Id+"/", nil, nil)
+}
+
+// Rename renames the library
+func (l *Library) Rename(newName string) error {
+	return l.RenameContext(context.Background(), newName)
+}
+
+// RenameContext is like Rename, using the given context for the requests.
+func (l *Library) RenameContext(ctx context.Context, newName string) error {
+	form := url.Values{
+		"repo_name": {newName},
+	}
+	if err := l.sf.req(ctx, "POST", "/repos/"+l.Id+"/?op=rename", form, nil); err != nil {
+		return err
 	}
-	return lib
+	l.Name = newName
+	return nil
 }
 
 // GetOwner returns the owner from the library
 func (l *Library) GetOwner() string {
+	return l.GetOwnerContext(context.Background())
+}
+
+// GetOwnerContext is like GetOwner, using the given context for the requests.
+func (l *Library) GetOwnerContext(ctx context.Context) string {
 	var own struct {
 		Owner string
 	}
-	if err := l.sf.req("GET", "/repos/"+l.Id+"/owner/", nil, &own); err != nil {
+	if err := l.sf.req(ctx, "GET", "/repos/"+l.Id+"/owner/", nil, &own); err != nil {
 		return ""
 	} else {
 		return own.Owner
 	}
 }
 
-// upload with a pipewriter -> stream upload
-func streamUpload(f io.Reader, filename, fieldname string, params map[string]string) (string, *io.PipeReader, error) {
-	// First handle closable resources
+// upload with a pipewriter -> stream upload. The returned channel is closed
+// when the background writer is done reading f.
+func streamUpload(ctx context.Context, f io.Reader, filename, fieldname string, params map[string]string) (string, *io.PipeReader, <-chan struct{}, error) {
 	r, w := io.Pipe()
-	rc, ok := f.(io.ReadCloser)
-	if !ok && r != nil {
-		rc = ioutil.NopCloser(r)
-	}
 	writer := multipart.NewWriter(w)
 	ctype := writer.FormDataContentType()
+	finished := make(chan struct{})
 	go func() {
+		defer close(finished)
 		// This runs in background and writes to the PipeWriter
 		// which blocks until something is read from the returned PipeReader
 		// This allows the streaming to be efficient and prevents loading
 		// the entire file in memory.
-		defer rc.Close()
-		defer writer.Close()
-		
+
+		// Abort a blocked write as soon as the context is done
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-ctx.Done():
+				w.CloseWithError(ctx.Err())
+			case <-done:
+			}
+		}()
+
 		// Send the file
 		if pw, err := writer.CreateFormFile(fieldname, filename); err != nil {
 			w.CloseWithError(err)
 			return
-		} else if _, err := io.Copy(pw, rc); err != nil {
+		} else if _, err := io.Copy(pw, f); err != nil {
 			w.CloseWithError(err)
 			return
 		}
@@ -106,76 +196,505 @@ func streamUpload(f io.Reader, filename, fieldname string, params map[string]str
 		for key, val := range params {
 			writer.WriteField(key, val)
 		}
+		// Write the closing boundary before closing the pipe
+		if err := writer.Close(); err != nil {
+			w.CloseWithError(err)
+			return
+		}
 		// Don't use defer, it's possible we use the CloseWithError above
 		w.Close()
 	}()
-	return ctype, r, nil
+	return ctype, r, finished, nil
 }
 
-
 // Upload uploads data from an io.Reader to a file with the specified
-// target path in the current library.
+// target path in the current library. If fileio is an io.Closer, it is
+// closed when the upload is done.
 func (l *Library) Upload(fileio io.Reader, tgtpath string) error {
+	return l.UploadContext(context.Background(), fileio, tgtpath)
+}
+
+// UploadContext is like Upload, using the given context for the requests.
+// If a retry policy is set and fileio is an io.Seeker, failed uploads are
+// retried after rewinding fileio. When the target file doesn't exist yet,
+// uploads interrupted by a connection reset or a server error are retried
+// too, replacing what the failed attempt may have stored. Otherwise only
+// uploads the server did not handle are retried, as a retry could store a
+// second copy of the file.
+func (l *Library) UploadContext(ctx context.Context, fileio io.Reader, tgtpath string) error {
+	replaceable := false
+	if _, ok := fileio.(io.Seeker); ok && l.sf.Retry.canRetry(1) {
+		_, err := l.StatContext(ctx, tgtpath)
+		replaceable = errors.Is(err, NotFoundError)
+	}
+	return l.sf.retryUpload(ctx, fileio, replaceable, func(replace bool) error {
+		return l.upload(ctx, fileio, tgtpath, replace)
+	})
+}
+
+// retryUpload calls upload until it succeeds or the retry policy gives up.
+// Retrying needs fileio to be an io.Seeker, so it can be rewound. If fileio
+// is an io.Closer, it is closed when done. Failed uploads the server may
+// have stored are only retried if replaceable is set, upload is then called
+// with replace set so the retry replaces the stored file.
+func (s *SeaFile) retryUpload(ctx context.Context, fileio io.Reader, replaceable bool, upload func(replace bool) error) error {
+	if c, ok := fileio.(io.Closer); ok {
+		defer c.Close()
+	}
+	// Remember where to rewind to for a retry
+	start := int64(-1)
+	if rs, ok := fileio.(io.Seeker); ok {
+		if pos, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			start = pos
+		}
+	}
+	replace := false
+	for attempt := 1; ; attempt++ {
+		err := upload(replace)
+		if err == nil || start < 0 || !s.Retry.canRetry(attempt) {
+			return err
+		}
+		// A replacing upload is idempotent, it's retried like a PUT
+		var header http.Header
+		var apierr *APIError
+		if errors.As(err, &apierr) {
+			header = apierr.header
+			if !retryableStatus("POST", apierr.StatusCode, header) {
+				if !replaceable || !retryableStatus("PUT", apierr.StatusCode, header) {
+					return err
+				}
+				replace = true
+			}
+		} else if !retryableErr("POST", err) {
+			if !replaceable || !retryableErr("PUT", err) {
+				return err
+			}
+			replace = true
+		}
+		if err := s.Retry.wait(ctx, s.logger(), attempt, header); err != nil {
+			return err
+		}
+		if _, err := fileio.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *Library) upload(ctx context.Context, fileio io.Reader, tgtpath string, replace bool) error {
 	// http://manual.seafile.com/develop/web_api.html#upload-file
 	// 1. Get upload url
 	var upllink string
-	if err := l.sf.req("GET", "/repos/"+l.Id+"/upload-link/", nil, &upllink); err != nil {
+	if err := l.sf.req(ctx, "GET", "/repos/"+l.Id+"/upload-link/", nil, &upllink); err != nil {
 		return err
 	}
 
 	// 2 - upload the file
+	tgtpath = filepath.Clean(tgtpath)
+	fn := filepath.Base(tgtpath)
+	tgtpath = filepath.Dir(tgtpath)
+	if tgtpath == "" {
+		tgtpath = "/"
+	}
+	return l.sf.postUpload(ctx, upllink, fileio, tgtpath, fn, replace)
+}
+
+// postUpload sends the content of fileio as a file named fn in the directory
+// parentDir to a fileserver upload link. If replace is set, an existing file
+// with the same name is replaced instead of storing the file under a new
+// name.
+func (s *SeaFile) postUpload(ctx context.Context, upllink string, fileio io.Reader, parentDir, fn string, replace bool) error {
 	// https://github.com/gebi/go-fileupload-example/blob/master/main.go
 	// http://matt.aimonetti.net/posts/2013/07/01/golang-multipart-file-upload-example/
-	if req, err := l.sf.newReq("POST", upllink); err != nil {
+	if req, err := s.newReq(ctx, "POST", upllink); err != nil {
 		return err
 	} else {
-		tgtpath = filepath.Clean(tgtpath)
-		fn := filepath.Base(tgtpath)
-		tgtpath = filepath.Dir(tgtpath)
-		if tgtpath == "" {
-			tgtpath = "/"
-		}
 		formval := map[string]string{
-			"parent_dir": tgtpath,
+			"parent_dir": parentDir,
 			"filename":   fn,
 			"__fake": "fake field",
 		}
-		if ctype, r, err := streamUpload(fileio, fn, "file", formval); err != nil {
+		if replace {
+			formval["replace"] = "1"
+		}
+		if ctype, r, finished, err := streamUpload(ctx, fileio, fn, "file", formval); err != nil {
 			return err
 		} else {
+			// The transport may still be reading the body when the response
+			// arrives. Stop the writer and wait for it, so fileio can be
+			// rewound for a retry.
+			defer func() {
+				r.Close()
+				<-finished
+			}()
 			req.Body = r
 			req.Header.Set("Content-Type", ctype)
 			// Now send the request
-			if resp, err := http.DefaultClient.Do(req); err != nil {
+			if resp, err := s.client().Do(req); err != nil {
 				return err
-			} else if resp.Status != "200 OK" {
-				return fmt.Errorf("expected return status '200 OK', got '%s'", resp.Status)
+			} else {
+				defer resp.Body.Close()
+				if err := s.checkResponse(resp, 200); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// Open opens the file at the specified path in the current library for
+// reading. The content is streamed from the server, the caller is responsible
+// for closing the returned io.ReadCloser.
+func (l *Library) Open(path string) (io.ReadCloser, error) {
+	return l.OpenContext(context.Background(), path)
+}
+
+// OpenContext is like Open, using the given context for the requests.
+func (l *Library) OpenContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	// http://manual.seafile.com/develop/web_api.html#download-file
+	// 1. Get download url
+	dllink, err := l.downloadLink(ctx, path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Fetch the file from the fileserver
+	if req, err := l.sf.newReq(ctx, "GET", dllink); err != nil {
+		return nil, err
+	} else if resp, err := l.sf.client().Do(req); err != nil {
+		return nil, err
+	} else if err := l.sf.checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	} else {
+		return resp.Body, nil
+	}
+}
+
+// OpenFile opens the file at the specified path in the current library and
+// returns a RemoteFile, which allows random access to the file content.
+func (l *Library) OpenFile(path string) (*RemoteFile, error) {
+	return l.OpenFileContext(context.Background(), path)
+}
+
+// OpenFileContext is like OpenFile, using the given context for the requests.
+func (l *Library) OpenFileContext(ctx context.Context, path string) (*RemoteFile, error) {
+	if f, err := l.StatContext(ctx, path); err != nil {
+		return nil, err
+	} else {
+		return f.OpenContext(ctx)
+	}
+}
+
+// downloadLink requests a fileserver link for the file at the specified path.
+// Reusable links stay valid for an hour instead of being invalidated after the
+// first request.
+func (l *Library) downloadLink(ctx context.Context, path string, reuse bool) (string, error) {
+	var dllink string
+	urls := "/repos/" + l.Id + "/file/?p=" + url.QueryEscape(path)
+	if reuse {
+		urls += "&reuse=1"
+	}
+	if err := l.sf.req(ctx, "GET", urls, nil, &dllink); err != nil {
+		return "", err
+	}
+	return dllink, nil
+}
+
+// Download downloads the file at the specified path in the current library
+// and writes its content to the given io.Writer.
+func (l *Library) Download(path string, w io.Writer) error {
+	return l.DownloadContext(context.Background(), path, w)
+}
+
+// DownloadContext is like Download, using the given context for the requests.
+func (l *Library) DownloadContext(ctx context.Context, path string, w io.Writer) error {
+	rc, err := l.OpenContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if _, err := io.Copy(w, rc); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Stat returns the File information of the file at the specified path.
+func (l *Library) Stat(path string) (*File, error) {
+	return l.StatContext(context.Background(), path)
+}
+
+// StatContext is like Stat, using the given context for the requests.
+func (l *Library) StatContext(ctx context.Context, path string) (*File, error) {
+	f := &File{lib: l, dir: pathpkg.Dir(pathpkg.Clean("/" + path))}
+	if err := l.sf.req(ctx, "GET", "/repos/"+l.Id+"/file/detail/?p="+url.QueryEscape(path), nil, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
 // List returns a list of all Files in the specified path.
 func (l *Library) List(path string) ([]File, error) {
+	return l.ListContext(context.Background(), path)
+}
+
+// ListContext is like List, using the given context for the requests.
+func (l *Library) ListContext(ctx context.Context, path string) ([]File, error) {
 	var flist []File
 	urls := "/repos/" + l.Id + "/dir/"
 	if path != "" {
 		urls = urls + "?p=" + url.QueryEscape(path)
 	}
-	if err := l.sf.req("GET", urls, nil, &flist); err != nil {
+	if err := l.sf.req(ctx, "GET", urls, nil, &flist); err != nil {
 		return nil, err
 	} else {
 		for i, _ := range flist {
 			flist[i].lib = l
+			flist[i].dir = pathpkg.Clean("/" + path)
 		}
 		return flist, nil
 	}
 }
 
+// Mkdir creates a directory with the specified path. If parents is true,
+// missing parent directories are created as well and no error is returned
+// when the directory already exists.
+func (l *Library) Mkdir(path string, parents bool) error {
+	return l.MkdirContext(context.Background(), path, parents)
+}
+
+// MkdirContext is like Mkdir, using the given context for the requests.
+func (l *Library) MkdirContext(ctx context.Context, path string, parents bool) error {
+	path = pathpkg.Clean("/" + path)
+	if !parents {
+		return l.mkdir(ctx, path)
+	}
+	if path == "/" {
+		return nil
+	}
+	if ok, err := l.dirExists(ctx, path); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+	if err := l.MkdirContext(ctx, pathpkg.Dir(path), true); err != nil {
+		return err
+	}
+	return l.mkdir(ctx, path)
+}
+
+func (l *Library) mkdir(ctx context.Context, path string) error {
+	form := url.Values{
+		"operation": {"mkdir"},
+	}
+	return l.sf.req(ctx, "POST", "/repos/"+l.Id+"/dir/?p="+url.QueryEscape(path), form, nil)
+}
+
+// dirExists checks if the directory with the specified path exists
+func (l *Library) dirExists(ctx context.Context, path string) (bool, error) {
+	if _, err := l.ListContext(ctx, path); errors.Is(err, NotFoundError) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveDir removes the directory with the specified path, including all
+// its contents.
+func (l *Library) RemoveDir(path string) error {
+	return l.RemoveDirContext(context.Background(), path)
+}
+
+// RemoveDirContext is like RemoveDir, using the given context for the requests.
+func (l *Library) RemoveDirContext(ctx context.Context, path string) error {
+	path = pathpkg.Clean("/" + path)
+	if path == "/" {
+		return fmt.Errorf("refusing to remove the library root")
+	}
+	return l.sf.req(ctx, "DELETE", "/repos/"+l.Id+"/dir/?p="+url.QueryEscape(path), nil, nil)
+}
+
+// RenameDir renames the directory with the specified path to newName. The
+// directory stays in the same parent directory.
+func (l *Library) RenameDir(path, newName string) error {
+	return l.RenameDirContext(context.Background(), path, newName)
+}
+
+// RenameDirContext is like RenameDir, using the given context for the requests.
+func (l *Library) RenameDirContext(ctx context.Context, path, newName string) error {
+	form := url.Values{
+		"operation": {"rename"},
+		"newname":   {newName},
+	}
+	return l.sf.req(ctx, "POST", "/repos/"+l.Id+"/dir/?p="+url.QueryEscape(pathpkg.Clean("/"+path)), form, nil)
+}
+
+// fileOp executes an operation on the file with the specified path
+func (l *Library) fileOp(ctx context.Context, path string, form url.Values) error {
+	return l.sf.req(ctx, "POST", "/repos/"+l.Id+"/file/?p="+url.QueryEscape(pathpkg.Clean("/"+path)), form, nil)
+}
+
+// batchOp executes an operation on multiple files or directories in the
+// directory with the specified path
+func (l *Library) batchOp(ctx context.Context, op, dir string, names []string, form url.Values) error {
+	if len(names) == 0 {
+		return fmt.Errorf("%s: no files specified", op)
+	}
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set("file_names", strings.Join(names, ":"))
+	return l.sf.req(ctx, "POST", "/repos/"+l.Id+"/fileops/"+op+"/?p="+url.QueryEscape(pathpkg.Clean("/"+dir)), form, nil)
+}
+
+// dstForm returns the form values pointing to the destination directory of a
+// move or copy. If dst is nil, the destination is the current library.
+func (l *Library) dstForm(op string, dst *Library, dstDir string) url.Values {
+	if dst == nil {
+		dst = l
+	}
+	form := url.Values{
+		"dst_repo": {dst.Id},
+		"dst_dir":  {pathpkg.Clean("/" + dstDir)},
+	}
+	if op != "" {
+		form.Set("operation", op)
+	}
+	return form
+}
+
+// MoveFile moves the file with the specified path to the directory dstDir in
+// the library dst. If dst is nil, the file is moved within the current
+// library.
+func (l *Library) MoveFile(path string, dst *Library, dstDir string) error {
+	return l.MoveFileContext(context.Background(), path, dst, dstDir)
+}
+
+// MoveFileContext is like MoveFile, using the given context for the requests.
+func (l *Library) MoveFileContext(ctx context.Context, path string, dst *Library, dstDir string) error {
+	return l.fileOp(ctx, path, l.dstForm("move", dst, dstDir))
+}
+
+// CopyFile copies the file with the specified path to the directory dstDir
+// in the library dst. If dst is nil, the file is copied within the current
+// library.
+func (l *Library) CopyFile(path string, dst *Library, dstDir string) error {
+	return l.CopyFileContext(context.Background(), path, dst, dstDir)
+}
+
+// CopyFileContext is like CopyFile, using the given context for the requests.
+func (l *Library) CopyFileContext(ctx context.Context, path string, dst *Library, dstDir string) error {
+	return l.fileOp(ctx, path, l.dstForm("copy", dst, dstDir))
+}
+
+// RenameFile renames the file with the specified path to newName. The file
+// stays in the same directory.
+func (l *Library) RenameFile(path, newName string) error {
+	return l.RenameFileContext(context.Background(), path, newName)
+}
+
+// RenameFileContext is like RenameFile, using the given context for the requests.
+func (l *Library) RenameFileContext(ctx context.Context, path, newName string) error {
+	form := url.Values{
+		"operation": {"rename"},
+		"newname":   {newName},
+	}
+	return l.fileOp(ctx, path, form)
+}
+
+// DeleteFile deletes the file with the specified path
+func (l *Library) DeleteFile(path string) error {
+	return l.DeleteFileContext(context.Background(), path)
+}
+
+// DeleteFileContext is like DeleteFile, using the given context for the requests.
+func (l *Library) DeleteFileContext(ctx context.Context, path string) error {
+	return l.sf.req(ctx, "DELETE", "/repos/"+l.Id+"/file/?p="+url.QueryEscape(pathpkg.Clean("/"+path)), nil, nil)
+}
+
+// MoveFiles moves the files or directories with the given names in the
+// directory dir to the directory dstDir in the library dst. If dst is nil,
+// the files are moved within the current library.
+func (l *Library) MoveFiles(dir string, names []string, dst *Library, dstDir string) error {
+	return l.MoveFilesContext(context.Background(), dir, names, dst, dstDir)
+}
+
+// MoveFilesContext is like MoveFiles, using the given context for the requests.
+func (l *Library) MoveFilesContext(ctx context.Context, dir string, names []string, dst *Library, dstDir string) error {
+	return l.batchOp(ctx, "move", dir, names, l.dstForm("", dst, dstDir))
+}
+
+// CopyFiles copies the files or directories with the given names in the
+// directory dir to the directory dstDir in the library dst. If dst is nil,
+// the files are copied within the current library.
+func (l *Library) CopyFiles(dir string, names []string, dst *Library, dstDir string) error {
+	return l.CopyFilesContext(context.Background(), dir, names, dst, dstDir)
+}
+
+// CopyFilesContext is like CopyFiles, using the given context for the requests.
+func (l *Library) CopyFilesContext(ctx context.Context, dir string, names []string, dst *Library, dstDir string) error {
+	return l.batchOp(ctx, "copy", dir, names, l.dstForm("", dst, dstDir))
+}
+
+// DeleteFiles deletes the files or directories with the given names in the
+// directory dir.
+func (l *Library) DeleteFiles(dir string, names []string) error {
+	return l.DeleteFilesContext(context.Background(), dir, names)
+}
+
+// DeleteFilesContext is like DeleteFiles, using the given context for the requests.
+func (l *Library) DeleteFilesContext(ctx context.Context, dir string, names []string) error {
+	return l.batchOp(ctx, "delete", dir, names, nil)
+}
+
+// unlock decrypts the library with the given id on the server, so it can be
+// accessed for a while.
+func (s *SeaFile) unlock(ctx context.Context, id, password string) error {
+	form := url.Values{
+		"password": {password},
+	}
+	return s.req(ctx, "POST", "/repos/"+id+"/", form, nil)
+}
+
+// SetPassword registers the password of an encrypted library, without
+// contacting the server. When a request fails because the library is locked,
+// it is unlocked with this password and the request is retried.
+func (l *Library) SetPassword(password string) {
+	if l.sf.libPasswords == nil {
+		l.sf.libPasswords = make(map[string]string)
+	}
+	l.sf.libPasswords[l.Id] = password
+}
+
+// Unlock unlocks an encrypted library with the given password. On success,
+// the password is registered to automatically unlock the library again when
+// the server locks it.
+func (l *Library) Unlock(password string) error {
+	return l.UnlockContext(context.Background(), password)
+}
+
+// UnlockContext is like Unlock, using the given context for the requests.
+func (l *Library) UnlockContext(ctx context.Context, password string) error {
+	if !l.Encrypted {
+		return fmt.Errorf("library '%s' is not encrypted", l.Name)
+	}
+	if err := l.sf.unlock(ctx, l.Id, password); err != nil {
+		return err
+	}
+	l.SetPassword(password)
+	return nil
+}
+
 // Update refreshes the Library information
 func (l *Library) Update() error {
-	if err := l.sf.req("GET", "/repos/"+l.Id+"/", nil, l); err != nil {
+	return l.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, using the given context for the requests.
+func (l *Library) UpdateContext(ctx context.Context) error {
+	if err := l.sf.req(ctx, "GET", "/repos/"+l.Id+"/", nil, l); err != nil {
 		return err
 	}
 	return nil
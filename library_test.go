@@ -0,0 +1,195 @@
+package goseafile
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploadRetryRewinds(t *testing.T) {
+	data := make([]byte, 16<<20)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	posts := 0
+	var received []byte
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api2/repos/r1/upload-link/":
+			fmt.Fprintf(w, `"%s/seafhttp/upload-api/0123456789abcdef"`, srv.URL)
+		case "/seafhttp/upload-api/0123456789abcdef":
+			mu.Lock()
+			posts++
+			first := posts == 1
+			mu.Unlock()
+			if first {
+				// Throttle after part of the file was sent
+				io.CopyN(ioutil.Discard, r.Body, 1<<20)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(429)
+				return
+			}
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("FormFile: %s", err)
+				w.WriteHeader(400)
+				return
+			}
+			b, _ := ioutil.ReadAll(f)
+			mu.Lock()
+			received = b
+			mu.Unlock()
+			fmt.Fprint(w, `"id"`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	sf := &SeaFile{Url: srv.URL, AuthToken: "token", Retry: &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}}
+	l := &Library{sf: sf, Id: "r1"}
+	if err := l.UploadContext(context.Background(), bytes.NewReader(data), "/dir/file.bin"); err != nil {
+		t.Fatalf("Upload: %s", err)
+	}
+	if posts != 2 {
+		t.Errorf("%d uploads, want 2", posts)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("uploaded content differs from the file")
+	}
+}
+
+func TestUploadRetryReplaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		wantPosts   int
+		wantReplace string
+		wantErr     bool
+	}{
+		{"new file", false, 2, "1", false},
+		{"existing file", true, 1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := 0
+			var replace string
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api2/repos/r1/file/detail/":
+					if !tt.exists {
+						w.WriteHeader(404)
+						return
+					}
+					fmt.Fprint(w, `{"name":"file.txt","type":"file"}`)
+				case "/api2/repos/r1/upload-link/":
+					fmt.Fprintf(w, `"%s/seafhttp/upload-api/0123456789abcdef"`, srv.URL)
+				case "/seafhttp/upload-api/0123456789abcdef":
+					posts++
+					r.ParseMultipartForm(1 << 20)
+					replace = r.FormValue("replace")
+					if posts == 1 {
+						// The server may have stored the file
+						w.WriteHeader(502)
+						return
+					}
+					fmt.Fprint(w, `"id"`)
+				default:
+					w.WriteHeader(404)
+				}
+			}))
+			defer srv.Close()
+
+			sf := &SeaFile{Url: srv.URL, AuthToken: "token", Retry: &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}}
+			l := &Library{sf: sf, Id: "r1"}
+			err := l.UploadContext(context.Background(), bytes.NewReader([]byte("content")), "/file.txt")
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Upload error = %v, want error %v", err, tt.wantErr)
+			}
+			if posts != tt.wantPosts {
+				t.Errorf("%d uploads, want %d", posts, tt.wantPosts)
+			}
+			if replace != tt.wantReplace {
+				t.Errorf("replace = %q on the last upload, want %q", replace, tt.wantReplace)
+			}
+		})
+	}
+}
+
+func TestAutoUnlock(t *testing.T) {
+	tests := []struct {
+		name string
+		// password is registered when not empty
+		password    string
+		stayLocked  bool
+		wantUnlocks int
+		wantGets    int
+		wantErr     error
+	}{
+		{"unlocked", "secret", false, 1, 2, nil},
+		{"no password", "", false, 0, 1, RepoPasswordRequiredError},
+		{"wrong password", "wrong", false, 1, 1, RepoPasswordRequiredError},
+		{"still locked", "secret", true, 1, 2, RepoPasswordRequiredError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unlocks, gets := 0, 0
+			locked := true
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "POST" && r.URL.Path == "/api2/repos/r1/":
+					unlocks++
+					if pw := r.FormValue("password"); pw != "secret" {
+						w.WriteHeader(400)
+						fmt.Fprint(w, `{"error_msg":"Wrong password"}`)
+						return
+					}
+					fmt.Fprint(w, `"success"`)
+					locked = tt.stayLocked
+				case r.Method == "GET" && r.URL.Path == "/api2/repos/r1/dir/":
+					gets++
+					if locked {
+						w.WriteHeader(440)
+						fmt.Fprint(w, `{"error_msg":"Library is encrypted."}`)
+						return
+					}
+					fmt.Fprint(w, `[]`)
+				default:
+					w.WriteHeader(404)
+				}
+			}))
+			defer srv.Close()
+
+			sf := &SeaFile{Url: srv.URL, AuthToken: "token"}
+			l := &Library{sf: sf, Id: "r1"}
+			if tt.password != "" {
+				l.SetPassword(tt.password)
+			}
+			var v []interface{}
+			err := sf.req(context.Background(), "GET", "/repos/r1/dir/?p=%2F", nil, &v)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("request failed: %s", err)
+			} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if unlocks != tt.wantUnlocks {
+				t.Errorf("%d unlocks, want %d", unlocks, tt.wantUnlocks)
+			}
+			if gets != tt.wantGets {
+				t.Errorf("%d requests, want %d", gets, tt.wantGets)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package goseafile
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	pathpkg "path"
+	"strconv"
+	"strings"
+)
+
+// Permission is the access level granted by sharing a library or directory
+type Permission string
+
+// Permissions a library or directory can be shared with
+const (
+	PermissionRead      Permission = "r"
+	PermissionReadWrite Permission = "rw"
+	PermissionAdmin     Permission = "admin"
+)
+
+// ParsePermission returns the Permission for "r", "rw" or "admin"
+func ParsePermission(perm string) (Permission, error) {
+	p := Permission(perm)
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// Validate returns an error if p is not one of the known permissions
+func (p Permission) Validate() error {
+	switch p {
+	case PermissionRead, PermissionReadWrite, PermissionAdmin:
+		return nil
+	}
+	return fmt.Errorf("invalid permission '%s', expected r, rw or admin", string(p))
+}
+
+// Share types
+const (
+	ShareTypeUser  = "user"
+	ShareTypeGroup = "group"
+)
+
+// Share describes who a library or directory is shared with
+type Share struct {
+	// ShareType is ShareTypeUser or ShareTypeGroup
+	ShareType string
+	// Path is the shared directory, "/" for the whole library
+	Path string
+	// User and UserName are set for user shares
+	User     string
+	UserName string
+	// GroupId and GroupName are set for group shares
+	GroupId    int
+	GroupName  string
+	Permission Permission
+}
+
+// sharedItem is a share as returned by the server
+type sharedItem struct {
+	ShareType string `json:"share_type"`
+	UserInfo  struct {
+		Name     string `json:"name"`
+		Nickname string `json:"nickname"`
+	} `json:"user_info"`
+	GroupInfo struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"group_info"`
+	Permission string `json:"permission"`
+	IsAdmin    bool   `json:"is_admin"`
+}
+
+// sharedItemsResult is the result of sharing with users or groups
+type sharedItemsResult struct {
+	Failed []struct {
+		ErrorMsg string `json:"error_msg"`
+	} `json:"failed"`
+}
+
+// ShareWithUser shares the library with the user with the given email
+func (l *Library) ShareWithUser(email string, perm Permission) error {
+	return l.ShareWithUserContext(context.Background(), email, perm)
+}
+
+// ShareWithUserContext is like ShareWithUser, using the given context for the requests.
+func (l *Library) ShareWithUserContext(ctx context.Context, email string, perm Permission) error {
+	return l.ShareDirWithUserContext(ctx, "/", email, perm)
+}
+
+// ShareDirWithUser shares the directory at path with the user with the given
+// email. If it is already shared with the user, the permission is updated.
+func (l *Library) ShareDirWithUser(path, email string, perm Permission) error {
+	return l.ShareDirWithUserContext(context.Background(), path, email, perm)
+}
+
+// ShareDirWithUserContext is like ShareDirWithUser, using the given context for the requests.
+func (l *Library) ShareDirWithUserContext(ctx context.Context, path, email string, perm Permission) error {
+	return l.share(ctx, path, url.Values{"share_type": {ShareTypeUser}, "username": {email}}, perm)
+}
+
+// ShareWithGroup shares the library with the group with the given id
+func (l *Library) ShareWithGroup(groupId int, perm Permission) error {
+	return l.ShareWithGroupContext(context.Background(), groupId, perm)
+}
+
+// ShareWithGroupContext is like ShareWithGroup, using the given context for the requests.
+func (l *Library) ShareWithGroupContext(ctx context.Context, groupId int, perm Permission) error {
+	return l.ShareDirWithGroupContext(ctx, "/", groupId, perm)
+}
+
+// ShareDirWithGroup shares the directory at path with the group with the
+// given id. If it is already shared with the group, the permission is
+// updated.
+func (l *Library) ShareDirWithGroup(path string, groupId int, perm Permission) error {
+	return l.ShareDirWithGroupContext(context.Background(), path, groupId, perm)
+}
+
+// ShareDirWithGroupContext is like ShareDirWithGroup, using the given context for the requests.
+func (l *Library) ShareDirWithGroupContext(ctx context.Context, path string, groupId int, perm Permission) error {
+	return l.share(ctx, path, url.Values{"share_type": {ShareTypeGroup}, "group_id": {strconv.Itoa(groupId)}}, perm)
+}
+
+// share shares the directory at path with the user or group in form
+func (l *Library) share(ctx context.Context, path string, form url.Values, perm Permission) error {
+	if err := perm.Validate(); err != nil {
+		return err
+	}
+	form.Set("permission", string(perm))
+	endpoint := "/repos/" + l.Id + "/dir/shared_items/?p=" + url.QueryEscape(pathpkg.Clean("/"+path))
+	var res sharedItemsResult
+	if err := l.sf.req(ctx, "PUT", endpoint, form, &res); err != nil {
+		return err
+	}
+	if len(res.Failed) == 0 {
+		return nil
+	}
+	// If it failed because it is already shared, update the permission
+	// instead. The error messages are translated, so look up the share.
+	if shared, err := l.sharedWith(ctx, path, form); err != nil {
+		return err
+	} else if shared {
+		return l.sf.req(ctx, "POST", endpoint, form, nil)
+	}
+	msgs := make([]string, len(res.Failed))
+	for i, f := range res.Failed {
+		msgs[i] = f.ErrorMsg
+	}
+	return fmt.Errorf("could not share '%s': %s", pathpkg.Clean("/"+path), strings.Join(msgs, ", "))
+}
+
+// sharedWith returns true if the directory at path is shared with the user
+// or group in form
+func (l *Library) sharedWith(ctx context.Context, path string, form url.Values) (bool, error) {
+	shares, err := l.ListDirSharesContext(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	for _, sh := range shares {
+		if sh.ShareType != form.Get("share_type") {
+			continue
+		}
+		if sh.ShareType == ShareTypeUser && sh.User == form.Get("username") {
+			return true, nil
+		} else if sh.ShareType == ShareTypeGroup && strconv.Itoa(sh.GroupId) == form.Get("group_id") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListShares returns the users and groups the library is shared with
+func (l *Library) ListShares() ([]*Share, error) {
+	return l.ListSharesContext(context.Background())
+}
+
+// ListSharesContext is like ListShares, using the given context for the requests.
+func (l *Library) ListSharesContext(ctx context.Context) ([]*Share, error) {
+	return l.ListDirSharesContext(ctx, "/")
+}
+
+// ListDirShares returns the users and groups the directory at path is shared
+// with
+func (l *Library) ListDirShares(path string) ([]*Share, error) {
+	return l.ListDirSharesContext(context.Background(), path)
+}
+
+// ListDirSharesContext is like ListDirShares, using the given context for the requests.
+func (l *Library) ListDirSharesContext(ctx context.Context, path string) ([]*Share, error) {
+	path = pathpkg.Clean("/" + path)
+	var ret []*Share
+	for _, st := range []string{ShareTypeUser, ShareTypeGroup} {
+		var items []sharedItem
+		endpoint := "/repos/" + l.Id + "/dir/shared_items/?p=" + url.QueryEscape(path) + "&share_type=" + st
+		if err := l.sf.req(ctx, "GET", endpoint, nil, &items); err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			sh := &Share{
+				ShareType:  it.ShareType,
+				Path:       path,
+				Permission: Permission(it.Permission),
+			}
+			if it.IsAdmin {
+				sh.Permission = PermissionAdmin
+			}
+			if it.ShareType == ShareTypeGroup {
+				sh.GroupId, sh.GroupName = it.GroupInfo.Id, it.GroupInfo.Name
+			} else {
+				sh.User, sh.UserName = it.UserInfo.Name, it.UserInfo.Nickname
+			}
+			ret = append(ret, sh)
+		}
+	}
+	return ret, nil
+}
+
+// Unshare removes the share of the library or directory with a user or
+// group, as returned by ListShares. For a user share only ShareType, User and
+// Path are needed, for a group share ShareType, GroupId and Path. An empty
+// Path is the whole library.
+func (l *Library) Unshare(sh *Share) error {
+	return l.UnshareContext(context.Background(), sh)
+}
+
+// UnshareContext is like Unshare, using the given context for the requests.
+func (l *Library) UnshareContext(ctx context.Context, sh *Share) error {
+	q := url.Values{
+		"p":          {pathpkg.Clean("/" + sh.Path)},
+		"share_type": {sh.ShareType},
+	}
+	switch sh.ShareType {
+	case ShareTypeUser:
+		q.Set("username", sh.User)
+	case ShareTypeGroup:
+		q.Set("group_id", strconv.Itoa(sh.GroupId))
+	default:
+		return fmt.Errorf("invalid share type '%s'", sh.ShareType)
+	}
+	return l.sf.req(ctx, "DELETE", "/repos/"+l.Id+"/dir/shared_items/?"+q.Encode(), nil, nil)
+}
@@ -0,0 +1,67 @@
+package goseafile
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShareFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		wantUpdate bool
+		wantErr    string
+	}{
+		{"shared", "new@example.com", false, ""},
+		{"already shared", "shared@example.com", true, ""},
+		{"unknown user", "unknown@example.com", false, "user unknown@example.com not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api2/repos/r1/dir/shared_items/" {
+					w.WriteHeader(404)
+					return
+				}
+				switch r.Method {
+				case "PUT":
+					r.ParseForm()
+					switch r.PostForm.Get("username") {
+					case "shared@example.com":
+						fmt.Fprint(w, `{"failed":[{"email":"shared@example.com","error_msg":"Dit item is al gedeeld met shared@example.com."}],"success":[]}`)
+					case "unknown@example.com":
+						fmt.Fprint(w, `{"failed":[{"email":"unknown@example.com","error_msg":"user unknown@example.com not found"}],"success":[]}`)
+					default:
+						fmt.Fprint(w, `{"failed":[],"success":[{}]}`)
+					}
+				case "POST":
+					updated = true
+					fmt.Fprint(w, `{"success":true}`)
+				case "GET":
+					if r.URL.Query().Get("share_type") == ShareTypeUser {
+						fmt.Fprint(w, `[{"share_type":"user","user_info":{"name":"shared@example.com","nickname":"Shared"},"permission":"r"}]`)
+					} else {
+						fmt.Fprint(w, `[]`)
+					}
+				}
+			}))
+			defer srv.Close()
+
+			l := &Library{sf: &SeaFile{Url: srv.URL, AuthToken: "token"}, Id: "r1"}
+			err := l.ShareWithUserContext(context.Background(), tt.email, PermissionReadWrite)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ShareWithUser: %s", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ShareWithUser error = %v, want %q", err, tt.wantErr)
+			}
+			if updated != tt.wantUpdate {
+				t.Errorf("permission updated = %v, want %v", updated, tt.wantUpdate)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package goseafile
+
+// lockFile is a no-op on platforms without flock. Concurrent updates of the
+// token file can then lose tokens, but the file is still replaced atomically.
+func lockFile(file string) (func(), error) {
+	return func() {}, nil
+}
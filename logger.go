@@ -0,0 +1,136 @@
+package goseafile
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Logger is the interface used for log output. Like log/slog, the methods
+// take a message followed by alternating keys and values, so a *slog.Logger
+// can be used directly.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// NewSlogLogger returns a Logger writing to the given slog.Logger. If l is
+// nil, slog.Default() is used.
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// NewStdLogger returns a Logger writing lines prefixed with [DEBUG], [WARN]
+// or [ERROR] to the given log.Logger, so the output can be filtered with
+// e.g. hashicorp/logutils. If l is nil, the standard logger of the log
+// package is used.
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l: l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (sl *stdLogger) Debug(msg string, args ...interface{}) { sl.output("DEBUG", msg, args) }
+func (sl *stdLogger) Warn(msg string, args ...interface{})  { sl.output("WARN", msg, args) }
+func (sl *stdLogger) Error(msg string, args ...interface{}) { sl.output("ERROR", msg, args) }
+
+func (sl *stdLogger) output(level, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString("[" + level + "] " + msg)
+	for i := 0; i < len(args); i += 2 {
+		var val interface{} = "!MISSING"
+		if i+1 < len(args) {
+			val = args[i+1]
+		}
+		v := fmt.Sprint(val)
+		if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, " %v=%s", args[i], v)
+	}
+	if sl.l != nil {
+		sl.l.Println(b.String())
+	} else {
+		log.Println(b.String())
+	}
+}
+
+func (s *SeaFile) logger() Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return nopLogger{}
+}
+
+// redact hides a secret like an authentication token for log output, only
+// keeping a short prefix to be able to tell tokens apart.
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return secret[:4] + "****"
+}
+
+// tokenSegments maps the path segments which are followed by an access
+// token to the position of the token after them.
+var tokenSegments = map[string]int{
+	// Fileserver links, /seafhttp/<operation>/<token>/...
+	"seafhttp": 2,
+	// Share and upload link API, e.g. /api/v2.1/share-links/<token>/dirents/
+	"share-links":  1,
+	"upload-links": 1,
+	// Share and upload link pages, /f/<token>/, /d/<token>/ and /u/d/<token>/
+	"f": 1,
+	"d": 1,
+}
+
+// tokenParams are the query parameters holding an access token
+var tokenParams = map[string]bool{"share_link_token": true, "token": true}
+
+// redactURL hides the access tokens in fileserver, share and upload links for
+// log output
+func redactURL(rurl string) string {
+	u, err := url.Parse(rurl)
+	if err != nil {
+		return "<invalid url>"
+	}
+	changed := false
+	parts := strings.Split(u.EscapedPath(), "/")
+	for i, p := range parts {
+		if off, ok := tokenSegments[p]; ok && i+off < len(parts) && parts[i+off] != "" {
+			parts[i+off] = redact(parts[i+off])
+			changed = true
+		}
+	}
+	if changed {
+		u.RawPath = strings.Join(parts, "/")
+		u.Path, _ = url.PathUnescape(u.RawPath)
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 && tokenParams[kv[0]] && kv[1] != "" {
+			params[i] = kv[0] + "=" + redact(kv[1])
+			changed = true
+		}
+	}
+	if !changed {
+		return rurl
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u.String()
+}
@@ -0,0 +1,46 @@
+package goseafile
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		secret, want string
+	}{
+		{"", "****"},
+		{"short", "****"},
+		{"12345678", "****"},
+		{"0123456789abcdef", "0123****"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.secret); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://h/seafhttp/files/0123456789abcdef/a%20b.txt", "https://h/seafhttp/files/0123****/a%20b.txt"},
+		{"https://h/seafhttp/upload-api/0123456789abcdef", "https://h/seafhttp/upload-api/0123****"},
+		{"https://h/api/v2.1/share-links/0123456789abcdef/", "https://h/api/v2.1/share-links/0123****/"},
+		{"https://h/sub/api/v2.1/share-links/0123456789abcdef/dirents/?path=%2F", "https://h/sub/api/v2.1/share-links/0123****/dirents/?path=%2F"},
+		{"https://h/api/v2.1/upload-links/0123456789abcdef/upload/", "https://h/api/v2.1/upload-links/0123****/upload/"},
+		{"https://h/api/v2.1/share-link-zip-task/?share_link_token=0123456789abcdef&path=%2F", "https://h/api/v2.1/share-link-zip-task/?share_link_token=0123****&path=%2F"},
+		{"https://h/api/v2.1/query-zip-progress/?token=0123456789abcdef", "https://h/api/v2.1/query-zip-progress/?token=0123****"},
+		{"https://h/f/0123456789abcdef/?dl=1", "https://h/f/0123****/?dl=1"},
+		{"https://h/u/d/0123456789abcdef/", "https://h/u/d/0123****/"},
+		// Nothing to redact
+		{"https://h/api/v2.1/share-links/?repo_id=abc", "https://h/api/v2.1/share-links/?repo_id=abc"},
+		{"https://h/api2/repos/abc/dir/?p=%2Fd%2Ff", "https://h/api2/repos/abc/dir/?p=%2Fd%2Ff"},
+		{"https://h/seafhttp/files/", "https://h/seafhttp/files/"},
+		{"/api2/repos/", "/api2/repos/"},
+		{"%zz", "<invalid url>"},
+	}
+	for _, tt := range tests {
+		if got := redactURL(tt.url); got != tt.want {
+			t.Errorf("redactURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package goseafile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LinkPasswordError indicates the password of a share or upload link was wrong
+var LinkPasswordError = fmt.Errorf("wrong link password")
+
+// passwordInput matches the password field of the form protecting a link
+var passwordInput = regexp.MustCompile(`(?i)<input[^>]+name=["']password["']`)
+
+// parseLink splits a public link like https://host/u/d/<token>/ into the
+// server url and the token. kinds are the path markers of the accepted link
+// types, e.g. "/u/d/".
+func parseLink(link string, kinds ...string) (base, token string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid link '%s': %s", link, err)
+	}
+	for _, kind := range kinds {
+		if i := strings.Index(u.Path, kind); i >= 0 {
+			token = strings.SplitN(u.Path[i+len(kind):], "/", 2)[0]
+			if token == "" {
+				break
+			}
+			u.Path = u.Path[:i]
+			u.RawQuery, u.Fragment = "", ""
+			return u.String(), token, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid link '%s': expected a link containing %s<token>/", link, strings.Join(kinds, "<token>/ or "))
+}
+
+// anonymous returns a SeaFile for the server at base without credentials,
+// using the transport settings of s. Cookies are kept, as they hold the
+// session for password protected links.
+func (s *SeaFile) anonymous(base string) *SeaFile {
+	c := *s.client()
+	if c.Jar == nil {
+		c.Jar, _ = cookiejar.New(nil)
+	}
+	return &SeaFile{
+		Url:           base,
+		HTTPClient:    &c,
+		Retry:         s.Retry,
+		Logger:        s.Logger,
+		FileServerUrl: s.FileServerUrl,
+	}
+}
+
+// unlockLink submits the password of a password protected link through its
+// web page, like a browser does. The server keeps the link unlocked for the
+// session in the cookie jar. On a wrong password, the server shows the
+// password form again, and an error wrapping LinkPasswordError is returned.
+func (s *SeaFile) unlockLink(ctx context.Context, link, token, password string) error {
+	// Fetch the password form first, for the CSRF cookie
+	if err := s.discard(ctx, "GET", link, nil, nil); err != nil {
+		return err
+	}
+	var csrf string
+	if u, err := url.Parse(link); err == nil && s.client().Jar != nil {
+		for _, c := range s.client().Jar.Cookies(u) {
+			if c.Name == "csrftoken" {
+				csrf = c.Value
+			}
+		}
+	}
+	form := url.Values{
+		"token":               {token},
+		"password":            {password},
+		"csrfmiddlewaretoken": {csrf},
+	}
+	if resp, err := s.reqResp(ctx, "POST", link, form, http.Header{"Referer": {link}}); err != nil {
+		return err
+	} else {
+		defer resp.Body.Close()
+		if err := s.checkResponse(resp); err != nil {
+			return err
+		}
+		if body, err := ioutil.ReadAll(resp.Body); err != nil {
+			return err
+		} else if passwordInput.Match(body) {
+			return fmt.Errorf("%w for '%s'", LinkPasswordError, redact(token))
+		}
+	}
+	return nil
+}
+
+// discard sends a request to a web page, ignoring the response body
+func (s *SeaFile) discard(ctx context.Context, method, link string, form url.Values, header http.Header) error {
+	if resp, err := s.reqResp(ctx, method, link, form, header); err != nil {
+		return err
+	} else {
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+		return s.checkResponse(resp)
+	}
+}
@@ -0,0 +1,121 @@
+package goseafile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures the automatic retry of requests which failed because
+// they were throttled (HTTP 429), because of a server error (HTTP 5xx) or
+// because of a transient network error. POST requests are not idempotent,
+// they are only retried when the server did not handle them: when throttled,
+// unavailable with a Retry-After header, or when the connection could not be
+// made.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// A value of 1 or less disables retrying.
+	MaxAttempts int
+	// MinBackoff is the backoff before the first retry, it is doubled for
+	// every following retry. Defaults to 1 second.
+	MinBackoff time.Duration
+	// MaxBackoff limits the backoff between retries. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+}
+
+// canRetry returns true if another attempt is allowed after the given number
+// of attempts.
+func (p *RetryPolicy) canRetry(attempt int) bool {
+	return p != nil && attempt < p.MaxAttempts
+}
+
+// backoff returns the time to wait before the next attempt. A Retry-After
+// header in the response takes precedence over the exponential backoff.
+func (p *RetryPolicy) backoff(attempt int, header http.Header) time.Duration {
+	if header != nil {
+		if ra := header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second
+			} else if t, err := http.ParseTime(ra); err == nil {
+				if d := time.Until(t); d > 0 {
+					return d
+				}
+				return 0
+			}
+		}
+	}
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = time.Second
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	// Wait between 50% and 100% of the backoff, so concurrent clients don't
+	// retry in lockstep
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// wait sleeps for the backoff of the given attempt, or until the context is
+// done.
+func (p *RetryPolicy) wait(ctx context.Context, lg Logger, attempt int, header http.Header) error {
+	d := p.backoff(attempt, header)
+	lg.Warn("Request failed, retrying", "attempt", attempt, "max_attempts", p.MaxAttempts, "backoff", d)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryableStatus returns true if a request with the given method which
+// failed with the given HTTP status and response header can be retried.
+func retryableStatus(method string, status int, header http.Header) bool {
+	if method == "POST" {
+		return status == 429 || (status == 503 && header.Get("Retry-After") != "")
+	}
+	switch status {
+	case 429, 500, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// retryableErr returns true if the error returned by the HTTP client for a
+// request with the given method is a transient network error.
+func retryableErr(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var oerr *net.OpError
+	if errors.As(err, &oerr) && oerr.Op == "dial" {
+		return true
+	} else if method == "POST" {
+		// The server may have received the request
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
@@ -0,0 +1,120 @@
+package goseafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		header   http.Header
+		min, max time.Duration
+	}{
+		{"defaults first", RetryPolicy{}, 1, nil, 500 * time.Millisecond, time.Second},
+		{"defaults third", RetryPolicy{}, 3, nil, 2 * time.Second, 4 * time.Second},
+		{"defaults capped", RetryPolicy{}, 20, nil, 15 * time.Second, 30 * time.Second},
+		{"custom", RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 2, nil, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"custom capped", RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}, 10, nil, 500 * time.Millisecond, time.Second},
+		{"retry-after seconds", RetryPolicy{}, 1, http.Header{"Retry-After": {"7"}}, 7 * time.Second, 7 * time.Second},
+		{"retry-after zero", RetryPolicy{}, 5, http.Header{"Retry-After": {"0"}}, 0, 0},
+		{"retry-after date", RetryPolicy{}, 1, http.Header{"Retry-After": {future}}, 59 * time.Minute, time.Hour},
+		{"retry-after past date", RetryPolicy{}, 1, http.Header{"Retry-After": {past}}, 0, 0},
+		{"retry-after invalid", RetryPolicy{}, 1, http.Header{"Retry-After": {"soon"}}, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if d := tt.policy.backoff(tt.attempt, tt.header); d < tt.min || d > tt.max {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	tests := []struct {
+		policy  *RetryPolicy
+		attempt int
+		want    bool
+	}{
+		{nil, 1, false},
+		{&RetryPolicy{MaxAttempts: 1}, 1, false},
+		{&RetryPolicy{MaxAttempts: 3}, 1, true},
+		{&RetryPolicy{MaxAttempts: 3}, 2, true},
+		{&RetryPolicy{MaxAttempts: 3}, 3, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.canRetry(tt.attempt); got != tt.want {
+			t.Errorf("%+v.canRetry(%d) = %v, want %v", tt.policy, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	retryAfter := http.Header{"Retry-After": {"1"}}
+	tests := []struct {
+		method string
+		status int
+		header http.Header
+		want   bool
+	}{
+		{"GET", 429, nil, true},
+		{"GET", 500, nil, true},
+		{"GET", 502, nil, true},
+		{"GET", 503, nil, true},
+		{"GET", 504, nil, true},
+		{"GET", 404, nil, false},
+		{"DELETE", 502, nil, true},
+		{"PUT", 504, nil, true},
+		{"POST", 429, nil, true},
+		{"POST", 503, retryAfter, true},
+		{"POST", 503, nil, false},
+		{"POST", 500, nil, false},
+		{"POST", 502, retryAfter, false},
+		{"POST", 504, nil, false},
+	}
+	for _, tt := range tests {
+		if got := retryableStatus(tt.method, tt.status, tt.header); got != tt.want {
+			t.Errorf("retryableStatus(%s, %d, %v) = %v, want %v", tt.method, tt.status, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRetryableErr(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	resetErr := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	tests := []struct {
+		method string
+		err    error
+		want   bool
+	}{
+		{"GET", dialErr, true},
+		{"POST", dialErr, true},
+		{"GET", resetErr, true},
+		{"POST", resetErr, false},
+		{"GET", io.ErrUnexpectedEOF, true},
+		{"POST", io.EOF, false},
+		{"GET", fmt.Errorf("wrapped: %w", io.EOF), true},
+		{"GET", context.Canceled, false},
+		{"GET", context.DeadlineExceeded, false},
+		{"GET", errors.New("other"), false},
+	}
+	for _, tt := range tests {
+		if got := retryableErr(tt.method, tt.err); got != tt.want {
+			t.Errorf("retryableErr(%s, %v) = %v, want %v", tt.method, tt.err, got, tt.want)
+		}
+	}
+}
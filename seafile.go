@@ -1,13 +1,15 @@
 package goseafile
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // SeaFile represents a SeaFile connection
@@ -18,7 +20,39 @@ type SeaFile struct {
 	User      string
 	Password  string
 
-	authTries int
+	// HTTPClient is the client used for all requests. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Retry configures retrying failed requests. If nil, requests are not
+	// retried.
+	Retry *RetryPolicy
+	// Logger receives the log output. If nil, nothing is logged.
+	Logger Logger
+	// TokenStore caches the authentication tokens. If nil, tokens are cached
+	// in the default token file when SaveAuth is set, otherwise they are
+	// not cached.
+	TokenStore TokenStore
+	// OTP is the two factor authentication code sent with the next login.
+	// It is cleared after a successful login, as codes can't be reused.
+	OTP string
+	// OTPFunc is called to get a two factor authentication code when the
+	// server requires one and OTP is empty, e.g. to prompt the user.
+	OTPFunc func(ctx context.Context) (string, error)
+	// TokenTTL is the time after which a cached token is not used anymore
+	// and the password is sent again. Defaults to 30 minutes, use
+	// TokenUntilRejected to keep using the token until the server rejects it.
+	TokenTTL time.Duration
+	// FileServerUrl is the url of the fileserver, FILE_SERVER_ROOT in the
+	// server configuration. It is only needed for downloads the server
+	// doesn't return a link for, like zipped share link directories.
+	// Defaults to the server url followed by /seafhttp.
+	FileServerUrl string
+
+	authTries    int
+	libPasswords map[string]string
+	// server capabilities, cached for capsUrl
+	capsUrl string
+	caps    *Capabilities
 }
 
 // AuthError indicates an authentication error
@@ -26,11 +60,99 @@ var AuthError           = fmt.Errorf("authentication error")
 // ThrottledError indicates the request was throttled by the server
 var ThrottledError      = fmt.Errorf("request was throttled")
 // NotFoundError indicates that an object or API endpoint could not be found
-var NotFoundError       = fmt.Errorf("request was throttled")
+var NotFoundError       = fmt.Errorf("not found")
 // OperationFailed indicates the operation failed
 var OperationFailed     = fmt.Errorf("operation failed")
 // InternalServerError indicates an internal server error
 var InternalServerError = fmt.Errorf("internal server error")
+// RepoPasswordRequiredError indicates the library is encrypted and needs to be unlocked
+var RepoPasswordRequiredError = fmt.Errorf("library password required")
+// RepoPasswordMagicRequiredError indicates the library is encrypted and needs the password magic
+var RepoPasswordMagicRequiredError = fmt.Errorf("library password magic required")
+// BadRequestError indicates the request was invalid, e.g. missing or wrong parameters
+var BadRequestError = fmt.Errorf("bad request")
+// ConflictError indicates the request conflicts with the current state on the server
+var ConflictError = fmt.Errorf("conflict")
+// OTPRequiredError indicates the server requires a two factor authentication code to log in
+var OTPRequiredError = fmt.Errorf("two factor authentication code required")
+// OTPInvalidError indicates the two factor authentication code was rejected
+var OTPInvalidError = fmt.Errorf("two factor authentication code invalid")
+
+// APIError is returned when the server responds with an unexpected status.
+// It wraps the matching sentinel error (AuthError, NotFoundError, ...), so
+// it can be checked with errors.Is.
+type APIError struct {
+	StatusCode int
+	Method     string
+	// Endpoint is the endpoint or url of the request, with access tokens
+	// redacted
+	Endpoint string
+	// Message is the error message returned by the server, if any
+	Message string
+
+	err    error
+	header http.Header
+}
+
+func (e *APIError) Error() string {
+	msg := e.err.Error()
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return fmt.Sprintf("%s %s: %s (status %d)", e.Method, e.Endpoint, msg, e.StatusCode)
+}
+
+// Unwrap returns the sentinel error matching the status code
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// newAPIError creates an *APIError for a failed request, decoding the
+// server's error message from the response body.
+func (s *SeaFile) newAPIError(resp *http.Response, method, endpoint string, err error) *APIError {
+	apierr := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Endpoint:   redactURL(endpoint),
+		err:        err,
+		header:     resp.Header,
+	}
+	if body, err := ioutil.ReadAll(resp.Body); err == nil {
+		s.logger().Debug("Request failed", "method", method, "endpoint", apierr.Endpoint, "status", resp.StatusCode, "body", string(body))
+		apierr.Message = errorMessage(body)
+	}
+	return apierr
+}
+
+// errorMessage extracts the error message from an error response body
+func errorMessage(body []byte) string {
+	var v struct {
+		ErrorMsg       string   `json:"error_msg"`
+		Detail         string   `json:"detail"`
+		NonFieldErrors []string `json:"non_field_errors"`
+	}
+	var str string
+	if err := json.Unmarshal(body, &v); err == nil {
+		if v.ErrorMsg != "" {
+			return v.ErrorMsg
+		} else if v.Detail != "" {
+			return v.Detail
+		}
+		return strings.Join(v.NonFieldErrors, " ")
+	} else if err := json.Unmarshal(body, &str); err == nil {
+		return str
+	}
+	return ""
+}
+
+// checkResponse returns an *APIError if the status of the response is not
+// one of the expected statuses.
+func (s *SeaFile) checkResponse(resp *http.Response, expectedstats ...int) error {
+	if err := getError(resp.StatusCode, expectedstats...); err != nil {
+		return s.newAPIError(resp, resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
 
 func getError(status int, expectedstats ...int) error {
 	if expectedstats == nil || len(expectedstats) == 0 {
@@ -46,18 +168,22 @@ func getError(status int, expectedstats ...int) error {
 		// moved
 	case 400:
 		// Bad request
+		return BadRequestError
 	case 403:
 		return AuthError
 	case 404:
 		return NotFoundError
 	case 409:
 		// conflict
+		return ConflictError
 	case 429:
 		return ThrottledError
 	case 440:
 		// repo password required
+		return RepoPasswordRequiredError
 	case 441:
 		// repo password magic required
+		return RepoPasswordMagicRequiredError
 	case 500:
 		// Internal server error
 		return InternalServerError
@@ -68,22 +194,55 @@ func getError(status int, expectedstats ...int) error {
 	return fmt.Errorf("unexpected http status: %d", status)
 }
 
-func (s *SeaFile) newReq(method, entry string) (*http.Request, error) {
+func (s *SeaFile) client() *http.Client {
+	if s.HTTPClient != nil {
+		return s.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// API version prefixes of the endpoints. Endpoints passed to req without
+// one of these prefixes are api2 endpoints.
+const (
+	api2   = "/api2"
+	apiV21 = "/api/v2.1"
+)
+
+// baseUrl returns the server url without trailing slash. For backwards
+// compatibility, Url may also point to the /api2 endpoint.
+func (s *SeaFile) baseUrl() string {
+	return strings.TrimSuffix(strings.TrimSuffix(s.Url, "/"), api2)
+}
+
+// fileServerUrl returns the url of the fileserver without trailing slash
+func (s *SeaFile) fileServerUrl() string {
+	if s.FileServerUrl != "" {
+		return strings.TrimSuffix(s.FileServerUrl, "/")
+	}
+	return s.baseUrl() + "/seafhttp"
+}
+
+// apiUrl returns the url of an API endpoint, which may be prefixed with its
+// API version.
+func (s *SeaFile) apiUrl(entry string) string {
+	entry = "/" + strings.TrimPrefix(entry, "/")
+	if !strings.HasPrefix(entry, api2+"/") && !strings.HasPrefix(entry, apiV21+"/") {
+		entry = api2 + entry
+	}
+	return s.baseUrl() + entry
+}
+
+func (s *SeaFile) newReq(ctx context.Context, method, entry string) (*http.Request, error) {
 	var rurl string
 	if strings.HasPrefix(entry, "http") {
 		rurl = entry
 	} else if s.Url == "" {
 		return nil, fmt.Errorf("no SeaFile API endpoint specified")
 	} else {
-		s.Url = strings.TrimSuffix(s.Url, "/")
-		// only support /api2 endpoint
-		if !strings.HasSuffix(s.Url, "/api2") {
-			s.Url += "/api2"
-		}
-		rurl = s.Url + "/" + strings.TrimPrefix(entry, "/")
+		rurl = s.apiUrl(entry)
 	}
-	log.Printf("[DEBUG] Sending request to: %s\n", rurl)
-	if req, err := http.NewRequest(method, rurl, nil); err != nil {
+	s.logger().Debug("Sending request", "method", method, "url", redactURL(rurl))
+	if req, err := http.NewRequestWithContext(ctx, method, rurl, nil); err != nil {
 		return nil, err
 	} else {
 		req.Header.Add("Accept", "application/json")
@@ -95,17 +254,20 @@ func (s *SeaFile) newReq(method, entry string) (*http.Request, error) {
 	}
 }
 
-func (s *SeaFile) reqResp(method, fnc string, form url.Values) (*http.Response, error) {
-	if req, err := s.newReq(method, fnc); err != nil {
+func (s *SeaFile) reqResp(ctx context.Context, method, fnc string, form url.Values, header http.Header) (*http.Response, error) {
+	if req, err := s.newReq(ctx, method, fnc); err != nil {
 		return nil, err
 	} else if req == nil {
 		return nil, fmt.Errorf("request nil")
 	} else {
+		for k, v := range header {
+			req.Header[k] = v
+		}
 		if form != nil {
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 			req.Body = ioutil.NopCloser(strings.NewReader(form.Encode()))
 		}
-		if resp, err := http.DefaultClient.Do(req); err != nil {
+		if resp, err := s.client().Do(req); err != nil {
 			return nil, err
 		} else {
 			return resp, nil
@@ -113,34 +275,82 @@ func (s *SeaFile) reqResp(method, fnc string, form url.Values) (*http.Response,
 	}
 }
 
-func (s *SeaFile) req(method, fnc string, form url.Values, rv interface{}) error {
+// repoId returns the library id from an API endpoint in the form of
+// /repos/{id}/..., or an empty string if the endpoint is not library specific
+func repoId(fnc string) string {
+	fnc = strings.TrimPrefix(fnc, apiV21)
+	fnc = strings.TrimPrefix(fnc, api2)
+	fnc = strings.TrimPrefix(fnc, "/")
+	if !strings.HasPrefix(fnc, "repos/") {
+		return ""
+	}
+	id := strings.TrimPrefix(fnc, "repos/")
+	if i := strings.IndexAny(id, "/?"); i >= 0 {
+		id = id[:i]
+	}
+	return id
+}
+
+func (s *SeaFile) req(ctx context.Context, method, fnc string, form url.Values, rv interface{}) error {
+	return s.reqHeader(ctx, method, fnc, form, nil, rv)
+}
 
-	for {
-		if resp, err := s.reqResp(method, fnc, form); err != nil {
+// reqHeader is like req, adding the given headers to the request
+func (s *SeaFile) reqHeader(ctx context.Context, method, fnc string, form url.Values, header http.Header, rv interface{}) error {
+	unlocked, reauthed := false, false
+	for attempt := 1; ; attempt++ {
+		if resp, err := s.reqResp(ctx, method, fnc, form, header); err != nil {
+			if retryableErr(method, err) && s.Retry.canRetry(attempt) {
+				if err := s.Retry.wait(ctx, s.logger(), attempt, nil); err != nil {
+					return err
+				}
+				continue
+			}
 			return err
 		} else {
 			defer resp.Body.Close()
 			if err := getError(resp.StatusCode); err != nil {
 				switch err {
 				case AuthError:
-					// Authenticate and retry?
-					log.Printf("[DEBUG] Authentication required, try to authenticate...\n")
-					if s.tryAuth() {
-						log.Printf("[DEBUG] Authentication succeeded, retry command...\n")
+					// Authenticate and retry once, a request which is still
+					// denied lacks permission
+					if reauthed || fnc == authTokenEndpoint {
+						break
+					}
+					reauthed = true
+					s.logger().Debug("Authentication required, try to authenticate...")
+					if err := s.tryAuth(ctx); err == nil {
+						s.logger().Debug("Authentication succeeded, retry command...")
 						continue
+					} else if errors.Is(err, OTPRequiredError) || errors.Is(err, OTPInvalidError) {
+						return err
+					}
+					return s.newAPIError(resp, method, fnc, err)
+				case RepoPasswordRequiredError, RepoPasswordMagicRequiredError:
+					// Unlock with the registered password and retry once
+					id := repoId(fnc)
+					if pw, ok := s.libPasswords[id]; ok && id != "" && !unlocked {
+						unlocked = true
+						s.logger().Debug("Library is locked, try to unlock...", "library", id)
+						if err := s.unlock(ctx, id, pw); err == nil {
+							s.logger().Debug("Library unlocked, retry command...", "library", id)
+							continue
+						} else {
+							s.logger().Warn("Could not unlock library", "library", id, "error", err)
+						}
 					}
-					return err
 				case ThrottledError:
-					log.Printf("[WARN] Request throttled!\n")
+					s.logger().Warn("Request throttled", "method", method, "endpoint", redactURL(fnc))
 				default:
 				}
-
-				if body, err := ioutil.ReadAll(resp.Body); err == nil {
-					log.Printf("[DEBUG] Request: %s %s\n", method, fnc)
-					log.Printf("[DEBUG] Body:\n---\n%s\n---\n", string(body))
+				if retryableStatus(method, resp.StatusCode, resp.Header) && s.Retry.canRetry(attempt) {
+					resp.Body.Close()
+					if err := s.Retry.wait(ctx, s.logger(), attempt, resp.Header); err != nil {
+						return err
+					}
+					continue
 				}
-				//return fmt.Errorf("request error: got status %d", resp.StatusCode)
-				return err
+				return s.newAPIError(resp, method, fnc, err)
 			}
 			if rv != nil {
 				/*
@@ -156,8 +366,7 @@ func (s *SeaFile) req(method, fnc string, form url.Values, rv interface{}) error
 					rd := strings.NewReader(string(body))
 					dec := json.NewDecoder(rd)
 					if err := dec.Decode(rv); err != nil {
-						log.Printf("[DEBUG] Error decoding body: %s\n", err)
-						log.Printf("[DEBUG] Body:\n----\n%s\n----\n", string(body))
+						s.logger().Debug("Error decoding body", "error", err, "body", string(body))
 						return err
 					}
 				}
@@ -170,7 +379,12 @@ func (s *SeaFile) req(method, fnc string, form url.Values, rv interface{}) error
 // Ping sends a ping request to the seafile server's API endpoint. Returns
 // true on success, false on failure.
 func (s *SeaFile) Ping() bool {
-	if resp, err := s.reqResp("GET", "/ping/", nil); err != nil {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is like Ping, using the given context for the requests.
+func (s *SeaFile) PingContext(ctx context.Context) bool {
+	if resp, err := s.reqResp(ctx, "GET", "/ping/", nil, nil); err != nil {
 		return false
 	} else {
 		defer resp.Body.Close()
@@ -185,17 +399,29 @@ func (s *SeaFile) Ping() bool {
 			} else if string(b) == "\"pong\"" {
 				return true
 			} else {
-				log.Printf("[ERROR] Unexpected value from ping: '%s'\n", string(b))
+				s.logger().Error("Unexpected value from ping", "value", string(b))
 			}
 		} else {
-			log.Printf("[ERROR] Unknown status code as response to ping: %d\n", resp.StatusCode)
+			s.logger().Error("Unknown status code as response to ping", "status", resp.StatusCode)
 		}
 	}
 	return false
 }
 
+// authTokenEndpoint is the login endpoint. A denied login is final, it never
+// triggers another login.
+const authTokenEndpoint = "/auth-token/"
+
 // Login logs into the seafile instance with the specified user and password.
+// If the server requires two factor authentication, the code is taken from
+// OTP or OTPFunc. Without a code, an error wrapping OTPRequiredError is
+// returned.
 func (s *SeaFile) Login(user string, password string) error {
+	return s.LoginContext(context.Background(), user, password)
+}
+
+// LoginContext is like Login, using the given context for the requests.
+func (s *SeaFile) LoginContext(ctx context.Context, user string, password string) error {
 	var tok struct {
 		Token string
 	}
@@ -203,24 +429,139 @@ func (s *SeaFile) Login(user string, password string) error {
 		"username": {user},
 		"password": {password},
 	}
-	if err := s.req("POST", "/auth-token/", v, &tok); err != nil {
-		return err
+	otp := s.OTP
+	for {
+		var header http.Header
+		if otp != "" {
+			header = http.Header{"X-Seafile-Otp": {otp}}
+		}
+		err := s.reqHeader(ctx, "POST", authTokenEndpoint, v, header, &tok)
+		if err == nil {
+			break
+		}
+		var apierr *APIError
+		if !errors.As(err, &apierr) || !otpRequired(apierr) {
+			return err
+		}
+		if otp != "" {
+			apierr.err = OTPInvalidError
+			return apierr
+		}
+		if s.OTPFunc == nil {
+			apierr.err = OTPRequiredError
+			return apierr
+		}
+		s.logger().Debug("Two factor authentication required, asking for code...")
+		if otp, err = s.OTPFunc(ctx); err != nil {
+			return err
+		} else if otp == "" {
+			apierr.err = OTPRequiredError
+			return apierr
+		}
 	}
 	s.AuthToken = tok.Token
+	s.OTP = ""
 	return nil
 }
 
+// Logout revokes the authentication token on the server and removes it from
+// the TokenStore. The token is removed from the cache even if revoking it
+// fails.
+func (s *SeaFile) Logout() error {
+	return s.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout, using the given context for the requests.
+func (s *SeaFile) LogoutContext(ctx context.Context) error {
+	store := s.tokenStore()
+	key := s.tokenKey()
+	tok := s.AuthToken
+	if tok == "" {
+		if st, err := store.Load(key); err != nil {
+			s.logger().Warn("Could not load stored token", "error", err)
+		} else if st != nil {
+			tok = st.Token
+		}
+	}
+	if err := store.Delete(key); err != nil {
+		return err
+	}
+	s.AuthToken = ""
+	if tok == "" {
+		return nil
+	}
+	// Don't go through req, a rejected token must not trigger a new login
+	header := http.Header{"Authorization": {"Token " + tok}}
+	if resp, err := s.reqResp(ctx, "POST", "/logout-device/", nil, header); err != nil {
+		return err
+	} else {
+		defer resp.Body.Close()
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			// Token was not valid anymore
+			return nil
+		}
+		return s.checkResponse(resp)
+	}
+}
+
+// AccountInfo holds the information about the authenticated user
+type AccountInfo struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Usage int64  `json:"usage"`
+	Total int64  `json:"total"`
+}
+
+// GetAccountInfo returns the information about the authenticated user
+func (s *SeaFile) GetAccountInfo() (*AccountInfo, error) {
+	return s.GetAccountInfoContext(context.Background())
+}
+
+// GetAccountInfoContext is like GetAccountInfo, using the given context for the requests.
+func (s *SeaFile) GetAccountInfoContext(ctx context.Context) (*AccountInfo, error) {
+	var ai AccountInfo
+	if err := s.req(ctx, "GET", "/account/info/", nil, &ai); err != nil {
+		return nil, err
+	}
+	return &ai, nil
+}
+
+// otpRequired returns true if a failed login needs a (new) two factor
+// authentication code.
+func otpRequired(apierr *APIError) bool {
+	if apierr.StatusCode != 400 {
+		return false
+	}
+	if strings.EqualFold(apierr.header.Get("X-Seafile-Otp"), "required") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(apierr.Message), "two factor auth token")
+}
+
 // Authed checks if we are currently authenticated (read: we have a valid authentication
 // token)
 func (s *SeaFile) Authed() bool {
+	return s.AuthedContext(context.Background())
+}
+
+// AuthedContext is like Authed, using the given context for the requests.
+func (s *SeaFile) AuthedContext(ctx context.Context) bool {
+	// Don't go through req, a rejected token must not trigger a new login
 	var rv string
-	if err := s.req("GET", "/auth/ping/", nil, &rv); err != nil {
-		log.Printf("[ERROR] auth/ping failed: %s\n", err)
-		s.AuthToken = ""
-		return false
-	} else if rv != "pong" {
+	if resp, err := s.reqResp(ctx, "GET", "/auth/ping/", nil, nil); err != nil {
+		s.logger().Error("auth/ping failed", "error", err)
 		s.AuthToken = ""
 		return false
+	} else {
+		defer resp.Body.Close()
+		if err := s.checkResponse(resp); err != nil {
+			s.logger().Error("auth/ping failed", "error", err)
+			s.AuthToken = ""
+			return false
+		} else if err := json.NewDecoder(resp.Body).Decode(&rv); err != nil || rv != "pong" {
+			s.AuthToken = ""
+			return false
+		}
 	}
 	return true
 }
@@ -0,0 +1,202 @@
+package goseafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReauthWithStaleToken(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 20 {
+			t.Errorf("too many requests")
+			w.WriteHeader(500)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		switch {
+		case auth == "Token stale":
+			// Seahub rejects a stale token on every endpoint, including
+			// the login
+			w.WriteHeader(403)
+			fmt.Fprint(w, `{"detail":"Invalid token"}`)
+		case r.URL.Path == "/api2/auth-token/":
+			if r.FormValue("password") != "secret" {
+				w.WriteHeader(400)
+				fmt.Fprint(w, `{"non_field_errors":["Unable to login with provided credentials."]}`)
+				return
+			}
+			fmt.Fprint(w, `{"token":"fresh"}`)
+		case auth != "Token fresh":
+			w.WriteHeader(403)
+		case r.URL.Path == "/api2/auth/ping/":
+			fmt.Fprint(w, `"pong"`)
+		case r.URL.Path == "/api2/repos/":
+			fmt.Fprint(w, `[{"id":"r1","name":"lib"}]`)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"valid password", "secret", false},
+		{"wrong password", "wrong", true},
+		{"no password", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			sf := &SeaFile{Url: srv.URL, User: "user", Password: tt.password, AuthToken: "stale"}
+			libs, err := sf.ListLibrariesContext(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ListLibraries succeeded, want error")
+				}
+			} else if err != nil {
+				t.Errorf("ListLibraries: %s", err)
+			} else if len(libs) != 1 || sf.AuthToken != "fresh" {
+				t.Errorf("ListLibraries = %d libraries with token %q, want 1 with token %q", len(libs), sf.AuthToken, "fresh")
+			}
+		})
+	}
+}
+
+func TestReauthOncePerRequest(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/api2/auth-token/":
+			fmt.Fprint(w, `{"token":"valid"}`)
+		case "/api2/auth/ping/":
+			fmt.Fprint(w, `"pong"`)
+		default:
+			// Permission denied, with a valid token
+			w.WriteHeader(403)
+		}
+	}))
+	defer srv.Close()
+	sf := &SeaFile{Url: srv.URL, User: "user", Password: "secret", AuthToken: "valid"}
+	if err := sf.req(context.Background(), "GET", "/repos/r1/", nil, nil); err == nil {
+		t.Errorf("request succeeded, want error")
+	}
+	// request, login, request
+	if requests > 3 {
+		t.Errorf("%d requests sent, want at most 3", requests)
+	}
+}
+
+func TestAPIErrorRedactsTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	}))
+	defer srv.Close()
+	sf := &SeaFile{Url: srv.URL, AuthToken: "token"}
+	tests := []string{
+		apiV21 + "/share-links/0123456789abcdef/",
+		srv.URL + "/seafhttp/files/0123456789abcdef/file.txt",
+	}
+	for _, endpoint := range tests {
+		err := sf.req(context.Background(), "GET", endpoint, nil, nil)
+		if err == nil {
+			t.Fatalf("GET %s succeeded, want error", endpoint)
+		}
+		if strings.Contains(err.Error(), "0123456789abcdef") {
+			t.Errorf("error %q contains the token", err)
+		}
+	}
+	// checkResponse uses the path of the request
+	resp, err := sf.reqResp(context.Background(), "GET", srv.URL+"/seafhttp/zip/0123456789abcdef", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := sf.checkResponse(resp); err == nil || strings.Contains(err.Error(), "0123456789abcdef") {
+		t.Errorf("checkResponse error = %v, want error without the token", err)
+	}
+}
+
+func TestLoginOTP(t *testing.T) {
+	funcErr := fmt.Errorf("prompt failed")
+	tests := []struct {
+		name string
+		otp  string
+		// code is returned by OTPFunc, which is not set when empty
+		code      string
+		codeErr   error
+		wantPosts int
+		wantErr   error
+	}{
+		{"no code", "", "", nil, 1, OTPRequiredError},
+		{"code set", "123456", "", nil, 1, nil},
+		{"code from OTPFunc", "", "123456", nil, 2, nil},
+		{"invalid code", "000000", "", nil, 1, OTPInvalidError},
+		{"invalid code from OTPFunc", "", "000000", nil, 2, OTPInvalidError},
+		{"set code preferred", "123456", "000000", nil, 1, nil},
+		{"OTPFunc fails", "", "123456", funcErr, 1, funcErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api2/auth-token/" {
+					w.WriteHeader(404)
+					return
+				}
+				posts++
+				if r.FormValue("username") != "user" || r.FormValue("password") != "secret" {
+					w.WriteHeader(400)
+					fmt.Fprint(w, `{"non_field_errors":["Unable to login with provided credentials."]}`)
+					return
+				}
+				switch r.Header.Get("X-Seafile-Otp") {
+				case "":
+					w.Header().Set("X-Seafile-Otp", "required")
+					w.WriteHeader(400)
+					fmt.Fprint(w, `{"non_field_errors":["Two factor auth token is missing."]}`)
+				case "123456":
+					fmt.Fprint(w, `{"token":"tok"}`)
+				default:
+					w.WriteHeader(400)
+					fmt.Fprint(w, `{"non_field_errors":["Two factor auth token is invalid."]}`)
+				}
+			}))
+			defer srv.Close()
+
+			sf := &SeaFile{Url: srv.URL, OTP: tt.otp}
+			if tt.code != "" {
+				sf.OTPFunc = func(ctx context.Context) (string, error) {
+					return tt.code, tt.codeErr
+				}
+			}
+			err := sf.LoginContext(context.Background(), "user", "secret")
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Login: %s", err)
+				}
+				if sf.AuthToken != "tok" {
+					t.Errorf("AuthToken = %q, want %q", sf.AuthToken, "tok")
+				}
+				if sf.OTP != "" {
+					t.Errorf("OTP = %q, want it cleared after login", sf.OTP)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login error = %v, want %v", err, tt.wantErr)
+			}
+			if posts != tt.wantPosts {
+				t.Errorf("%d login requests, want %d", posts, tt.wantPosts)
+			}
+		})
+	}
+}
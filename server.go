@@ -0,0 +1,174 @@
+package goseafile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnsupportedByServerError indicates the server is too old for the requested operation
+var UnsupportedByServerError = fmt.Errorf("unsupported by server")
+
+// ServerInfo holds the information returned by /server-info/
+type ServerInfo struct {
+	Version                 string   `json:"version"`
+	Features                []string `json:"features"`
+	EncryptedLibraryVersion int      `json:"encrypted_library_version"`
+}
+
+// Edition returns "pro" for the professional edition of the server,
+// "community" otherwise.
+func (si *ServerInfo) Edition() string {
+	if si.HasFeature("seafile-pro") {
+		return "pro"
+	}
+	return "community"
+}
+
+// HasFeature returns true if the server reports the given feature, e.g.
+// "file-search" or "office-preview".
+func (si *ServerInfo) HasFeature(feature string) bool {
+	for _, f := range si.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// AtLeast returns true if the server version is the given version or newer.
+// An unknown version is considered older than any version.
+func (si *ServerInfo) AtLeast(version string) bool {
+	return si.Version != "" && compareVersions(si.Version, version) >= 0
+}
+
+// Capabilities describes what the server supports, derived from its
+// ServerInfo.
+type Capabilities struct {
+	ServerInfo
+	// Pro is set for the professional edition
+	Pro bool
+	// APIv21 is set if the server has the /api/v2.1/ endpoints (6.0+)
+	APIv21 bool
+	// FileSearch is set if the server supports searching files
+	FileSearch bool
+	// OfficePreview is set if the server can preview office documents
+	OfficePreview bool
+}
+
+func newCapabilities(si ServerInfo) *Capabilities {
+	return &Capabilities{
+		ServerInfo:    si,
+		Pro:           si.HasFeature("seafile-pro"),
+		APIv21:        si.AtLeast("6.0"),
+		FileSearch:    si.HasFeature("file-search"),
+		OfficePreview: si.HasFeature("office-preview"),
+	}
+}
+
+// ServerInfo retrieves the version and features of the server. It also
+// refreshes the cached Capabilities.
+func (s *SeaFile) ServerInfo() (*ServerInfo, error) {
+	return s.ServerInfoContext(context.Background())
+}
+
+// ServerInfoContext is like ServerInfo, using the given context for the requests.
+func (s *SeaFile) ServerInfoContext(ctx context.Context) (*ServerInfo, error) {
+	var si ServerInfo
+	if err := s.req(ctx, "GET", "/server-info/", nil, &si); err != nil {
+		return nil, err
+	}
+	s.capsUrl, s.caps = s.baseUrl(), newCapabilities(si)
+	return &si, nil
+}
+
+// Capabilities returns what the server supports. It is retrieved once per
+// server url and cached. Servers which don't know /server-info/ result in
+// empty Capabilities.
+func (s *SeaFile) Capabilities() (*Capabilities, error) {
+	return s.CapabilitiesContext(context.Background())
+}
+
+// CapabilitiesContext is like Capabilities, using the given context for the requests.
+func (s *SeaFile) CapabilitiesContext(ctx context.Context) (*Capabilities, error) {
+	if s.caps != nil && s.capsUrl == s.baseUrl() {
+		return s.caps, nil
+	}
+	if _, err := s.ServerInfoContext(ctx); err != nil {
+		if !errors.Is(err, NotFoundError) {
+			return nil, err
+		}
+		s.capsUrl, s.caps = s.baseUrl(), newCapabilities(ServerInfo{})
+	}
+	return s.caps, nil
+}
+
+// PingLatency sends a ping request to the server and returns the time it
+// took to get the response.
+func (s *SeaFile) PingLatency() (time.Duration, error) {
+	return s.PingLatencyContext(context.Background())
+}
+
+// PingLatencyContext is like PingLatency, using the given context for the requests.
+func (s *SeaFile) PingLatencyContext(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if resp, err := s.reqResp(ctx, "GET", "/ping/", nil, nil); err != nil {
+		return 0, err
+	} else {
+		defer resp.Body.Close()
+		// Read the body, so the latency covers the complete response
+		if _, err := ioutil.ReadAll(resp.Body); err != nil {
+			return 0, err
+		}
+		d := time.Since(start)
+		// Throttled, but the server did answer
+		if resp.StatusCode == 429 {
+			return d, nil
+		}
+		if err := s.checkResponse(resp); err != nil {
+			return 0, err
+		}
+		return d, nil
+	}
+}
+
+// requireVersion returns an error wrapping UnsupportedByServerError if the
+// server is older than the given version. If the server version is unknown,
+// the request is tried, and the server decides whether it supports it.
+func (s *SeaFile) requireVersion(ctx context.Context, feature, min string) error {
+	caps, err := s.CapabilitiesContext(ctx)
+	if err != nil {
+		return err
+	}
+	if caps.Version == "" {
+		s.logger().Debug("Server version unknown, trying anyway", "feature", feature, "min_version", min)
+	} else if !caps.AtLeast(min) {
+		return fmt.Errorf("%w: %s requires seafile %s or newer, server version is %s", UnsupportedByServerError, feature, min, caps.Version)
+	}
+	return nil
+}
+
+// compareVersions compares dotted version numbers like 7.1.5, returning -1,
+// 0 or 1. Missing or non-numeric parts count as 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+	}
+	return 0
+}
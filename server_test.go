@@ -0,0 +1,44 @@
+package goseafile
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"7.1.5", "7.1.5", 0},
+		{"7.1", "7.1.0", 0},
+		{"6.0", "6", 0},
+		{"7.1.5", "7.1.4", 1},
+		{"7.1.4", "7.1.5", -1},
+		{"10.0", "9.0", 1},
+		{"6.3.4", "7.0", -1},
+		{"7.0.1", "7.0", 1},
+		{"", "6.0", -1},
+		{"7.x", "7.0", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestServerInfoAtLeast(t *testing.T) {
+	tests := []struct {
+		version, min string
+		want         bool
+	}{
+		{"7.1.5", "6.0", true},
+		{"6.0.0", "6.0", true},
+		{"5.1.4", "6.0", false},
+		{"", "6.0", false},
+	}
+	for _, tt := range tests {
+		si := ServerInfo{Version: tt.version}
+		if got := si.AtLeast(tt.min); got != tt.want {
+			t.Errorf("ServerInfo{Version: %q}.AtLeast(%q) = %v, want %v", tt.version, tt.min, got, tt.want)
+		}
+	}
+}
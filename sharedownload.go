@@ -0,0 +1,219 @@
+package goseafile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+// PublicShareLink is a share link opened without a SeaFile account, to list
+// and download its content.
+type PublicShareLink struct {
+	Token string
+	// IsDir is set for links to a directory
+	IsDir bool
+
+	sf   *SeaFile
+	base string
+}
+
+// ShareLinkEntry is a file or directory in a directory share link
+type ShareLinkEntry struct {
+	Name  string
+	Path  string
+	IsDir bool
+	Size  int64
+	// LastModified is an ISO 8601 timestamp
+	LastModified string
+}
+
+// ShareLinkFile is a file downloaded from a share link. The caller is
+// responsible for closing it.
+type ShareLinkFile struct {
+	io.ReadCloser
+	Name string
+	// Size is -1 if unknown
+	Size int64
+}
+
+// OpenShareLink opens a public share link, e.g.
+// https://seafile.example.com/f/0123456789abcdef/ for a file or
+// https://seafile.example.com/d/0123456789abcdef/ for a directory. Only the
+// HTTP client, retry policy and logger of s are used, no account is needed.
+// If password is not empty, it is submitted to unlock the link, a wrong
+// password results in an error wrapping LinkPasswordError.
+func (s *SeaFile) OpenShareLink(link, password string) (*PublicShareLink, error) {
+	return s.OpenShareLinkContext(context.Background(), link, password)
+}
+
+// OpenShareLinkContext is like OpenShareLink, using the given context for the requests.
+func (s *SeaFile) OpenShareLinkContext(ctx context.Context, link, password string) (*PublicShareLink, error) {
+	base, token, err := parseLink(link, "/f/", "/d/")
+	if err != nil {
+		return nil, err
+	}
+	psl := &PublicShareLink{
+		Token: token,
+		IsDir: strings.Contains(link, "/d/"+token),
+		sf:    s.anonymous(base),
+		base:  base,
+	}
+	if password != "" {
+		if err := psl.sf.unlockLink(ctx, psl.linkUrl(), token, password); err != nil {
+			return nil, err
+		}
+	}
+	return psl, nil
+}
+
+func (psl *PublicShareLink) linkUrl() string {
+	if psl.IsDir {
+		return psl.base + "/d/" + psl.Token + "/"
+	}
+	return psl.base + "/f/" + psl.Token + "/"
+}
+
+// List returns the content of the directory at path in a directory share
+// link.
+func (psl *PublicShareLink) List(path string) ([]*ShareLinkEntry, error) {
+	return psl.ListContext(context.Background(), path)
+}
+
+// ListContext is like List, using the given context for the requests.
+func (psl *PublicShareLink) ListContext(ctx context.Context, path string) ([]*ShareLinkEntry, error) {
+	if !psl.IsDir {
+		return nil, fmt.Errorf("share link '%s' is not a directory", psl.Token)
+	}
+	var v struct {
+		DirentList []struct {
+			IsDir        bool   `json:"is_dir"`
+			FileName     string `json:"file_name"`
+			FilePath     string `json:"file_path"`
+			FolderName   string `json:"folder_name"`
+			FolderPath   string `json:"folder_path"`
+			Size         int64  `json:"size"`
+			LastModified string `json:"last_modified"`
+		} `json:"dirent_list"`
+	}
+	q := url.Values{"path": {pathpkg.Clean("/" + path)}}
+	if err := psl.sf.req(ctx, "GET", apiV21+"/share-links/"+url.PathEscape(psl.Token)+"/dirents/?"+q.Encode(), nil, &v); err != nil {
+		return nil, err
+	}
+	var ret []*ShareLinkEntry
+	for _, d := range v.DirentList {
+		e := &ShareLinkEntry{
+			Name:         d.FileName,
+			Path:         d.FilePath,
+			IsDir:        d.IsDir,
+			Size:         d.Size,
+			LastModified: d.LastModified,
+		}
+		if d.IsDir {
+			e.Name, e.Path = d.FolderName, d.FolderPath
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// Open downloads a file from the share link. For file links, path is
+// ignored. For directory links, it is the path of the file in the directory.
+func (psl *PublicShareLink) Open(path string) (*ShareLinkFile, error) {
+	return psl.OpenContext(context.Background(), path)
+}
+
+// OpenContext is like Open, using the given context for the requests.
+func (psl *PublicShareLink) OpenContext(ctx context.Context, path string) (*ShareLinkFile, error) {
+	link := psl.linkUrl() + "?dl=1"
+	if psl.IsDir {
+		link = psl.linkUrl() + "files/?" + url.Values{"p": {pathpkg.Clean("/" + path)}, "dl": {"1"}}.Encode()
+	}
+	return psl.open(ctx, link)
+}
+
+// OpenZip downloads the directory at path in a directory share link as a
+// zip file. The server prepares the zip file first, which can take a while
+// for large directories.
+func (psl *PublicShareLink) OpenZip(path string) (*ShareLinkFile, error) {
+	return psl.OpenZipContext(context.Background(), path)
+}
+
+// OpenZipContext is like OpenZip, using the given context for the requests.
+func (psl *PublicShareLink) OpenZipContext(ctx context.Context, path string) (*ShareLinkFile, error) {
+	if !psl.IsDir {
+		return nil, fmt.Errorf("share link '%s' is not a directory", psl.Token)
+	}
+	path = pathpkg.Clean("/" + path)
+	var task struct {
+		ZipToken string `json:"zip_token"`
+	}
+	q := url.Values{"share_link_token": {psl.Token}, "path": {path}}
+	if err := psl.sf.req(ctx, "GET", apiV21+"/share-link-zip-task/?"+q.Encode(), nil, &task); err != nil {
+		return nil, err
+	}
+	// Wait until the zip file is ready
+	for {
+		var progress struct {
+			Zipped       int    `json:"zipped"`
+			Total        int    `json:"total"`
+			Failed       int    `json:"failed"`
+			FailedReason string `json:"failed_reason"`
+		}
+		q := url.Values{"token": {task.ZipToken}}
+		if err := psl.sf.req(ctx, "GET", apiV21+"/query-zip-progress/?"+q.Encode(), nil, &progress); err != nil {
+			return nil, err
+		}
+		if progress.Failed != 0 {
+			return nil, fmt.Errorf("could not zip '%s': %s", path, progress.FailedReason)
+		} else if progress.Zipped >= progress.Total {
+			break
+		}
+		psl.sf.logger().Debug("Waiting for zip file", "zipped", progress.Zipped, "total", progress.Total)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	f, err := psl.open(ctx, psl.sf.fileServerUrl()+"/zip/"+url.PathEscape(task.ZipToken))
+	if err != nil {
+		return nil, err
+	}
+	name := pathpkg.Base(path)
+	if path == "/" {
+		name = psl.Token
+	}
+	f.Name = name + ".zip"
+	return f, nil
+}
+
+// open starts a download, following the redirect to the fileserver
+func (psl *PublicShareLink) open(ctx context.Context, link string) (*ShareLinkFile, error) {
+	resp, err := psl.sf.reqResp(ctx, "GET", link, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := psl.sf.checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	// Instead of the file, the page asking for the password is returned
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct == "text/html" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("share link '%s' is password protected, or the password is wrong", psl.Token)
+	}
+	f := &ShareLinkFile{
+		ReadCloser: resp.Body,
+		Name:       pathpkg.Base(resp.Request.URL.Path),
+		Size:       resp.ContentLength,
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		f.Name = pathpkg.Base(params["filename"])
+	}
+	return f, nil
+}
@@ -0,0 +1,118 @@
+package goseafile
+
+import (
+	"context"
+	"net/url"
+	pathpkg "path"
+	"strconv"
+	"time"
+)
+
+// ShareLink is a public download link to a file or directory in a library
+type ShareLink struct {
+	Token    string `json:"token"`
+	Link     string `json:"link"`
+	RepoId   string `json:"repo_id"`
+	RepoName string `json:"repo_name"`
+	Path     string `json:"path"`
+	ObjName  string `json:"obj_name"`
+	IsDir    bool   `json:"is_dir"`
+	Username string `json:"username"`
+	// ViewCount is the number of times the link was opened
+	ViewCount int `json:"view_cnt"`
+	// Ctime and ExpireDate are ISO 8601 timestamps, ExpireDate is empty if
+	// the link doesn't expire
+	Ctime      string `json:"ctime"`
+	ExpireDate string `json:"expire_date"`
+	IsExpired  bool   `json:"is_expired"`
+}
+
+// Expires returns the time the link expires, or the zero time if it doesn't
+// expire.
+func (sl *ShareLink) Expires() time.Time {
+	if t, err := time.Parse(time.RFC3339, sl.ExpireDate); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// CreateShareLink creates a download link for the file or directory at path.
+// If password is not empty, the link is protected with it. If expireDays is
+// larger than 0, the link expires after that many days.
+func (l *Library) CreateShareLink(path, password string, expireDays int) (*ShareLink, error) {
+	return l.CreateShareLinkContext(context.Background(), path, password, expireDays)
+}
+
+// CreateShareLinkContext is like CreateShareLink, using the given context for the requests.
+func (l *Library) CreateShareLinkContext(ctx context.Context, path, password string, expireDays int) (*ShareLink, error) {
+	if err := l.sf.requireVersion(ctx, "share links", "6.0"); err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"repo_id": {l.Id},
+		"path":    {pathpkg.Clean("/" + path)},
+	}
+	if password != "" {
+		form.Set("password", password)
+	}
+	if expireDays > 0 {
+		form.Set("expire_days", strconv.Itoa(expireDays))
+	}
+	var sl ShareLink
+	if err := l.sf.req(ctx, "POST", apiV21+"/share-links/", form, &sl); err != nil {
+		return nil, err
+	}
+	return &sl, nil
+}
+
+// ListShareLinks returns the download links of the file or directory at
+// path, or of the whole library if path is empty.
+func (l *Library) ListShareLinks(path string) ([]*ShareLink, error) {
+	return l.ListShareLinksContext(context.Background(), path)
+}
+
+// ListShareLinksContext is like ListShareLinks, using the given context for the requests.
+func (l *Library) ListShareLinksContext(ctx context.Context, path string) ([]*ShareLink, error) {
+	if err := l.sf.requireVersion(ctx, "share links", "6.0"); err != nil {
+		return nil, err
+	}
+	q := url.Values{"repo_id": {l.Id}}
+	if path != "" {
+		q.Set("path", pathpkg.Clean("/"+path))
+	}
+	var v []*ShareLink
+	if err := l.sf.req(ctx, "GET", apiV21+"/share-links/?"+q.Encode(), nil, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetShareLink returns the download link with the given token
+func (s *SeaFile) GetShareLink(token string) (*ShareLink, error) {
+	return s.GetShareLinkContext(context.Background(), token)
+}
+
+// GetShareLinkContext is like GetShareLink, using the given context for the requests.
+func (s *SeaFile) GetShareLinkContext(ctx context.Context, token string) (*ShareLink, error) {
+	if err := s.requireVersion(ctx, "share links", "6.0"); err != nil {
+		return nil, err
+	}
+	var sl ShareLink
+	if err := s.req(ctx, "GET", apiV21+"/share-links/"+url.PathEscape(token)+"/", nil, &sl); err != nil {
+		return nil, err
+	}
+	return &sl, nil
+}
+
+// DeleteShareLink removes the download link with the given token
+func (s *SeaFile) DeleteShareLink(token string) error {
+	return s.DeleteShareLinkContext(context.Background(), token)
+}
+
+// DeleteShareLinkContext is like DeleteShareLink, using the given context for the requests.
+func (s *SeaFile) DeleteShareLinkContext(ctx context.Context, token string) error {
+	if err := s.requireVersion(ctx, "share links", "6.0"); err != nil {
+		return err
+	}
+	return s.req(ctx, "DELETE", apiV21+"/share-links/"+url.PathEscape(token)+"/", nil, nil)
+}
@@ -0,0 +1,137 @@
+package goseafile
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultTokenMaxAge is the age after which a cached token is not used anymore
+const defaultTokenMaxAge = 30 * time.Minute
+
+// TokenUntilRejected can be used as SeaFile.TokenTTL to keep using a cached
+// token until the server rejects it.
+const TokenUntilRejected time.Duration = -1
+
+// TokenKey identifies a cached authentication token. The password is not
+// part of the identity of the token, but stores can use it to encrypt the
+// token at rest.
+type TokenKey struct {
+	Url      string
+	User     string
+	Password string
+}
+
+// StoredToken is an authentication token kept in a TokenStore
+type StoredToken struct {
+	Token string
+	// TimeStamp is the time the token was obtained
+	TimeStamp time.Time
+	// LastValidated is the last time the token was accepted by the server
+	LastValidated time.Time
+}
+
+// TokenStore caches authentication tokens, so the password doesn't need to be
+// sent to the server for every session.
+type TokenStore interface {
+	// Load returns the token stored for the key, or nil if there is none.
+	Load(key TokenKey) (*StoredToken, error)
+	// Save stores the token for the key, replacing any existing token.
+	Save(key TokenKey, tok StoredToken) error
+	// Delete removes the token stored for the key, if any.
+	Delete(key TokenKey) error
+}
+
+// tokenStore returns the TokenStore to use: the configured one, the default
+// token file if SaveAuth is set, or a store that doesn't cache anything.
+func (s *SeaFile) tokenStore() TokenStore {
+	if s.TokenStore != nil {
+		return s.TokenStore
+	}
+	if s.SaveAuth {
+		fs := &FileTokenStore{
+			Logger: s.logger(),
+		}
+		if ttl := s.tokenTTL(); ttl > 0 {
+			fs.MaxAge = ttl
+		}
+		return fs
+	}
+	return NopTokenStore{}
+}
+
+// tokenKey returns the key of the authentication token for the current url
+// and user
+func (s *SeaFile) tokenKey() TokenKey {
+	return TokenKey{
+		// Url used to be rewritten to the api2 endpoint, keep using that so
+		// existing tokens are found
+		Url:      s.baseUrl() + api2,
+		User:     s.User,
+		Password: s.Password,
+	}
+}
+
+// tokenTTL returns the time a cached token is used, or a negative duration if
+// it is used until it is rejected.
+func (s *SeaFile) tokenTTL() time.Duration {
+	if s.TokenTTL == 0 {
+		return defaultTokenMaxAge
+	}
+	return s.TokenTTL
+}
+
+// NopTokenStore is a TokenStore which doesn't store anything
+type NopTokenStore struct{}
+
+// Load always returns nil
+func (NopTokenStore) Load(key TokenKey) (*StoredToken, error) { return nil, nil }
+
+// Save discards the token
+func (NopTokenStore) Save(key TokenKey, tok StoredToken) error { return nil }
+
+// Delete does nothing
+func (NopTokenStore) Delete(key TokenKey) error { return nil }
+
+// MemoryTokenStore is a TokenStore keeping the tokens in memory, e.g. to share
+// them between several SeaFile instances in one process. It is safe for
+// concurrent use.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]StoredToken
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{
+		tokens: make(map[string]StoredToken),
+	}
+}
+
+// Load returns the token stored for the key, or nil if there is none.
+func (m *MemoryTokenStore) Load(key TokenKey) (*StoredToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tok, ok := m.tokens[tokenId(key)]; ok {
+		return &tok, nil
+	}
+	return nil, nil
+}
+
+// Save stores the token for the key
+func (m *MemoryTokenStore) Save(key TokenKey, tok StoredToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tokens == nil {
+		m.tokens = make(map[string]StoredToken)
+	}
+	m.tokens[tokenId(key)] = tok
+	return nil
+}
+
+// Delete removes the token stored for the key
+func (m *MemoryTokenStore) Delete(key TokenKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, tokenId(key))
+	return nil
+}
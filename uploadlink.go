@@ -0,0 +1,160 @@
+package goseafile
+
+import (
+	"context"
+	"io"
+	"net/url"
+	pathpkg "path"
+	"strconv"
+)
+
+// UploadLink is a public link which allows anyone to upload files to a
+// directory in a library
+type UploadLink struct {
+	Token    string `json:"token"`
+	Link     string `json:"link"`
+	RepoId   string `json:"repo_id"`
+	RepoName string `json:"repo_name"`
+	Path     string `json:"path"`
+	ObjName  string `json:"obj_name"`
+	Username string `json:"username"`
+	// ViewCount is the number of times the link was opened
+	ViewCount int `json:"view_cnt"`
+	// Ctime and ExpireDate are ISO 8601 timestamps, ExpireDate is empty if
+	// the link doesn't expire
+	Ctime      string `json:"ctime"`
+	ExpireDate string `json:"expire_date"`
+	IsExpired  bool   `json:"is_expired"`
+}
+
+// CreateUploadLink creates an upload link for the directory dir. If password
+// is not empty, the link is protected with it. If expireDays is larger than
+// 0, the link expires after that many days.
+func (l *Library) CreateUploadLink(dir, password string, expireDays int) (*UploadLink, error) {
+	return l.CreateUploadLinkContext(context.Background(), dir, password, expireDays)
+}
+
+// CreateUploadLinkContext is like CreateUploadLink, using the given context for the requests.
+func (l *Library) CreateUploadLinkContext(ctx context.Context, dir, password string, expireDays int) (*UploadLink, error) {
+	if err := l.sf.requireVersion(ctx, "upload links", "6.0"); err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"repo_id": {l.Id},
+		"path":    {pathpkg.Clean("/" + dir)},
+	}
+	if password != "" {
+		form.Set("password", password)
+	}
+	if expireDays > 0 {
+		form.Set("expire_days", strconv.Itoa(expireDays))
+	}
+	var ul UploadLink
+	if err := l.sf.req(ctx, "POST", apiV21+"/upload-links/", form, &ul); err != nil {
+		return nil, err
+	}
+	return &ul, nil
+}
+
+// ListUploadLinks returns the upload links of the directory dir, or of the
+// whole library if dir is empty.
+func (l *Library) ListUploadLinks(dir string) ([]*UploadLink, error) {
+	return l.ListUploadLinksContext(context.Background(), dir)
+}
+
+// ListUploadLinksContext is like ListUploadLinks, using the given context for the requests.
+func (l *Library) ListUploadLinksContext(ctx context.Context, dir string) ([]*UploadLink, error) {
+	if err := l.sf.requireVersion(ctx, "upload links", "6.0"); err != nil {
+		return nil, err
+	}
+	q := url.Values{"repo_id": {l.Id}}
+	if dir != "" {
+		q.Set("path", pathpkg.Clean("/"+dir))
+	}
+	var v []*UploadLink
+	if err := l.sf.req(ctx, "GET", apiV21+"/upload-links/?"+q.Encode(), nil, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DeleteUploadLink removes the upload link with the given token
+func (s *SeaFile) DeleteUploadLink(token string) error {
+	return s.DeleteUploadLinkContext(context.Background(), token)
+}
+
+// DeleteUploadLinkContext is like DeleteUploadLink, using the given context for the requests.
+func (s *SeaFile) DeleteUploadLinkContext(ctx context.Context, token string) error {
+	if err := s.requireVersion(ctx, "upload links", "6.0"); err != nil {
+		return err
+	}
+	return s.req(ctx, "DELETE", apiV21+"/upload-links/"+url.PathEscape(token)+"/", nil, nil)
+}
+
+// UploadLinkClient uploads files through a public upload link, without a
+// SeaFile account.
+type UploadLinkClient struct {
+	// Dir is the directory to upload to, as shown on the page of the link.
+	// The server only accepts uploads to the directory the link points to,
+	// or below it. Defaults to "/".
+	Dir string
+
+	sf    *SeaFile
+	token string
+}
+
+// OpenUploadLink returns a client for the public upload link, e.g.
+// https://seafile.example.com/u/d/0123456789abcdef/. Only the HTTP client,
+// retry policy and logger of s are used, no account is needed. If password
+// is not empty, it is submitted to unlock the link, a wrong password results
+// in an error wrapping LinkPasswordError.
+func (s *SeaFile) OpenUploadLink(link, password string) (*UploadLinkClient, error) {
+	return s.OpenUploadLinkContext(context.Background(), link, password)
+}
+
+// OpenUploadLinkContext is like OpenUploadLink, using the given context for the requests.
+func (s *SeaFile) OpenUploadLinkContext(ctx context.Context, link, password string) (*UploadLinkClient, error) {
+	base, token, err := parseLink(link, "/u/d/")
+	if err != nil {
+		return nil, err
+	}
+	c := &UploadLinkClient{
+		Dir:   "/",
+		sf:    s.anonymous(base),
+		token: token,
+	}
+	if password != "" {
+		if err := c.sf.unlockLink(ctx, link, token, password); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Upload uploads data from an io.Reader to a file with the given name in
+// Dir. If fileio is an io.Closer, it is closed when the upload is done.
+func (c *UploadLinkClient) Upload(fileio io.Reader, name string) error {
+	return c.UploadContext(context.Background(), fileio, name)
+}
+
+// UploadContext is like Upload, using the given context for the requests.
+// If a retry policy is set and fileio is an io.Seeker, uploads the server
+// did not handle are retried after rewinding fileio. Uploads interrupted by
+// a connection reset or a server error are not retried, the link can't
+// replace a file a failed attempt may have stored.
+func (c *UploadLinkClient) UploadContext(ctx context.Context, fileio io.Reader, name string) error {
+	return c.sf.retryUpload(ctx, fileio, false, func(bool) error {
+		// Get a fileserver link for the upload
+		var v struct {
+			UploadLink string `json:"upload_link"`
+		}
+		if err := c.sf.req(ctx, "GET", apiV21+"/upload-links/"+url.PathEscape(c.token)+"/upload/", nil, &v); err != nil {
+			return err
+		}
+		dir := c.Dir
+		if dir == "" {
+			dir = "/"
+		}
+		return c.sf.postUpload(ctx, v.UploadLink, fileio, pathpkg.Clean("/"+dir), name, false)
+	})
+}